@@ -0,0 +1,267 @@
+package gometer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AlertSource determines which value of a metric is checked by a rule.
+type AlertSource int
+
+const (
+	// AlertValue checks the current value of a metric.
+	AlertValue AlertSource = iota
+	// AlertDelta checks the change of a metric since the previous evaluation.
+	AlertDelta
+	// AlertRate checks the per-second rate of a metric since the previous evaluation.
+	AlertRate
+)
+
+// AlertOp determines how a value is compared with a threshold.
+type AlertOp int
+
+const (
+	// AlertAbove matches values greater than a threshold.
+	AlertAbove AlertOp = iota
+	// AlertBelow matches values less than a threshold.
+	AlertBelow
+)
+
+// AlertState represents a state of a rule.
+type AlertState int
+
+const (
+	// AlertInactive means that a rule doesn't match or is resolved.
+	AlertInactive AlertState = iota
+	// AlertPending means that a rule matches for less than its For duration.
+	AlertPending
+	// AlertFiring means that a rule matches for at least its For duration.
+	AlertFiring
+)
+
+// String returns a name of a state.
+func (s AlertState) String() string {
+	switch s {
+	case AlertInactive:
+		return "inactive"
+	case AlertPending:
+		return "pending"
+	case AlertFiring:
+		return "firing"
+	default:
+		return fmt.Sprintf("AlertState(%d)", int(s))
+	}
+}
+
+// AlertRule represents a condition on a metric.
+//
+// A rule fires when its value is compared with Threshold by Op successfully
+// for at least For duration. A firing rule is resolved only when its value
+// crosses Threshold by at least Hysteresis, that prevents flapping of values
+// near a threshold. Delta and rate of a monotonic counter that was reset
+// are computed from zero.
+type AlertRule struct {
+	Name       string
+	Metric     string
+	Source     AlertSource
+	Op         AlertOp
+	Threshold  float64
+	Hysteresis float64
+	For        time.Duration
+}
+
+func (r AlertRule) matches(v float64) bool {
+	if r.Op == AlertBelow {
+		return v < r.Threshold
+	}
+	return v > r.Threshold
+}
+
+func (r AlertRule) resolved(v float64) bool {
+	if r.Op == AlertBelow {
+		return v >= r.Threshold+r.Hysteresis
+	}
+	return v <= r.Threshold-r.Hysteresis
+}
+
+// AlertEvent represents a state transition of a rule.
+//
+// State is AlertFiring when a rule fires and AlertInactive when it's resolved.
+type AlertEvent struct {
+	Rule  AlertRule
+	State AlertState
+	Value float64
+	Time  time.Time
+}
+
+// AlertParams represents params of an alerter.
+//
+// Handler is called on every firing and resolving of rules.
+// Interval is an interval of evaluation of rules started by Alerter.Start(),
+// it may be zero if rules are only evaluated by Alerter.Evaluate().
+// Now is used to get the current time, time.Now is used if it's nil.
+type AlertParams struct {
+	Rules    []AlertRule
+	Handler  func(AlertEvent)
+	Interval time.Duration
+	Now      func() time.Time
+}
+
+type alertRuleState struct {
+	state AlertState
+	since time.Time
+}
+
+// Alerter evaluates rules on values of metrics.
+type Alerter struct {
+	m      Metrics
+	params AlertParams
+
+	mu     sync.Mutex
+	prev   Snapshot
+	states []alertRuleState
+}
+
+// NewAlerter creates an alerter of m, it returns an error if rules are invalid.
+func NewAlerter(m Metrics, params AlertParams) (*Alerter, error) {
+	if params.Handler == nil {
+		return nil, errors.New("gometer: alert handler is nil")
+	}
+	names := make(map[string]struct{}, len(params.Rules))
+	for _, r := range params.Rules {
+		if r.Name == "" || r.Metric == "" {
+			return nil, fmt.Errorf("gometer: alert rule %q has no name or metric", r.Name)
+		}
+		if _, ok := names[r.Name]; ok {
+			return nil, fmt.Errorf("gometer: duplicate alert rule %q", r.Name)
+		}
+		names[r.Name] = struct{}{}
+		if r.Hysteresis < 0 || r.For < 0 {
+			return nil, fmt.Errorf("gometer: alert rule %q has negative hysteresis or duration", r.Name)
+		}
+	}
+	if params.Interval < 0 {
+		return nil, errors.New("gometer: alert interval is negative")
+	}
+	if params.Now == nil {
+		params.Now = time.Now
+	}
+	params.Rules = append([]AlertRule(nil), params.Rules...)
+
+	return &Alerter{
+		m:      m,
+		params: params,
+		states: make([]alertRuleState, len(params.Rules)),
+	}, nil
+}
+
+// Start starts a goroutine that evaluates rules every params.Interval.
+// It returns an error if the interval isn't positive.
+func (a *Alerter) Start() (Stopper, error) {
+	if a.params.Interval <= 0 {
+		return nil, errors.New("gometer: alert interval isn't positive")
+	}
+	return startPeriodic(a.params.Interval, false, a.Evaluate), nil
+}
+
+// Evaluate evaluates all rules once and calls the handler on state transitions.
+//
+// Rules on deltas and rates are evaluated starting from the second call.
+// Rules on missing metrics keep their state.
+func (a *Alerter) Evaluate() {
+	var events []AlertEvent
+
+	a.mu.Lock()
+	cur := a.m.Snapshot()
+	cur.Time = a.params.Now()
+
+	var changes map[string]MetricChange
+	if !a.prev.Time.IsZero() && cur.Time.After(a.prev.Time) {
+		d := Diff(a.prev, cur, DiffParams{})
+		changes = make(map[string]MetricChange, len(d.Changes))
+		for _, c := range d.Changes {
+			changes[c.Name] = c
+		}
+	}
+
+	for i, r := range a.params.Rules {
+		v, ok := a.value(r, cur, changes)
+		if !ok {
+			continue
+		}
+		if e, ok := a.transition(i, v, cur.Time); ok {
+			events = append(events, e)
+		}
+	}
+	a.prev = cur
+	a.mu.Unlock()
+
+	for _, e := range events {
+		a.params.Handler(e)
+	}
+}
+
+func (a *Alerter) value(r AlertRule, cur Snapshot, changes map[string]MetricChange) (float64, bool) {
+	if r.Source == AlertValue {
+		e, ok := cur.Get(r.Metric)
+		if !ok {
+			return 0, false
+		}
+		return e.Value(), true
+	}
+
+	c, ok := changes[r.Metric]
+	if !ok {
+		return 0, false
+	}
+	if r.Source == AlertRate {
+		return c.Rate, true
+	}
+	return c.Delta, true
+}
+
+func (a *Alerter) transition(i int, v float64, now time.Time) (AlertEvent, bool) {
+	r := a.params.Rules[i]
+	s := &a.states[i]
+
+	switch s.state {
+	case AlertInactive:
+		if !r.matches(v) {
+			return AlertEvent{}, false
+		}
+		s.state, s.since = AlertPending, now
+		fallthrough
+	case AlertPending:
+		if !r.matches(v) {
+			s.state = AlertInactive
+			return AlertEvent{}, false
+		}
+		if now.Sub(s.since) < r.For {
+			return AlertEvent{}, false
+		}
+		s.state = AlertFiring
+		return AlertEvent{Rule: r, State: AlertFiring, Value: v, Time: now}, true
+	case AlertFiring:
+		if !r.resolved(v) {
+			return AlertEvent{}, false
+		}
+		s.state = AlertInactive
+		return AlertEvent{Rule: r, State: AlertInactive, Value: v, Time: now}, true
+	}
+	return AlertEvent{}, false
+}
+
+// State returns the current state of a rule.
+func (a *Alerter) State(rule string) AlertState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i, r := range a.params.Rules {
+		if r.Name == rule {
+			return a.states[i].state
+		}
+	}
+	return AlertInactive
+}
@@ -0,0 +1,165 @@
+package gometer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestAlerter(t *testing.T) {
+	metrics := New()
+	errorsTotal := metrics.GetMonotonic("errors_total")
+	queueLen := metrics.Get("queue_len")
+
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var events []AlertEvent
+	a, err := NewAlerter(metrics, AlertParams{
+		Rules: []AlertRule{
+			{Name: "errors", Metric: "errors_total", Source: AlertRate, Threshold: 100.0 / 60},
+			{Name: "queue", Metric: "queue_len", Threshold: 1000, Hysteresis: 100, For: 30 * time.Second},
+		},
+		Handler: func(e AlertEvent) { events = append(events, e) },
+		Now:     clock.Now,
+	})
+	require.Nil(t, err)
+
+	// the first evaluation has no rates.
+	queueLen.Set(2000)
+	a.Evaluate()
+	assert.Empty(t, events)
+	assert.Equal(t, AlertPending, a.State("queue"))
+
+	clock.Advance(20 * time.Second)
+	require.Nil(t, errorsTotal.Add(100))
+	a.Evaluate()
+	require.Len(t, events, 1)
+	assert.Equal(t, "errors", events[0].Rule.Name)
+	assert.Equal(t, AlertFiring, events[0].State)
+	assert.Equal(t, float64(5), events[0].Value)
+	assert.Equal(t, AlertPending, a.State("queue"))
+
+	// the queue rule fires after 30s, the errors rule is resolved.
+	clock.Advance(10 * time.Second)
+	a.Evaluate()
+	require.Len(t, events, 3)
+	assert.Equal(t, AlertEvent{Rule: a.params.Rules[0], State: AlertInactive, Value: 0, Time: clock.now}, events[1])
+	assert.Equal(t, AlertEvent{Rule: a.params.Rules[1], State: AlertFiring, Value: 2000, Time: clock.now}, events[2])
+
+	// the queue rule isn't resolved within hysteresis.
+	queueLen.Set(950)
+	clock.Advance(time.Second)
+	a.Evaluate()
+	assert.Len(t, events, 3)
+	assert.Equal(t, AlertFiring, a.State("queue"))
+
+	queueLen.Set(900)
+	clock.Advance(time.Second)
+	a.Evaluate()
+	require.Len(t, events, 4)
+	assert.Equal(t, "queue", events[3].Rule.Name)
+	assert.Equal(t, AlertInactive, events[3].State)
+
+	// the pending state is reset when a rule stops matching.
+	queueLen.Set(2000)
+	a.Evaluate()
+	clock.Advance(20 * time.Second)
+	queueLen.Set(10)
+	a.Evaluate()
+	queueLen.Set(2000)
+	clock.Advance(20 * time.Second)
+	a.Evaluate()
+	assert.Len(t, events, 4)
+	assert.Equal(t, AlertPending, a.State("queue"))
+}
+
+func TestAlerterDeltaBelow(t *testing.T) {
+	metrics := New()
+	processed := metrics.GetMonotonic("processed")
+
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var events []AlertEvent
+	a, err := NewAlerter(metrics, AlertParams{
+		Rules: []AlertRule{
+			{Name: "stalled", Metric: "processed", Source: AlertDelta, Op: AlertBelow, Threshold: 1},
+			{Name: "missing", Metric: "missing", Threshold: 1},
+		},
+		Handler: func(e AlertEvent) { events = append(events, e) },
+		Now:     clock.Now,
+	})
+	require.Nil(t, err)
+
+	require.Nil(t, processed.Add(10))
+	a.Evaluate()
+	clock.Advance(time.Second)
+	a.Evaluate()
+	require.Len(t, events, 1)
+	assert.Equal(t, AlertFiring, events[0].State)
+
+	require.Nil(t, processed.Add(5))
+	clock.Advance(time.Second)
+	a.Evaluate()
+	require.Len(t, events, 2)
+	assert.Equal(t, AlertInactive, events[1].State)
+	assert.Equal(t, float64(5), events[1].Value)
+	assert.Equal(t, AlertInactive, a.State("missing"))
+}
+
+func TestAlerterInvalidRules(t *testing.T) {
+	handler := func(AlertEvent) {}
+	for _, params := range []AlertParams{
+		{},
+		{Handler: handler, Rules: []AlertRule{{Name: "a"}}},
+		{Handler: handler, Rules: []AlertRule{{Name: "a", Metric: "a"}, {Name: "a", Metric: "b"}}},
+		{Handler: handler, Rules: []AlertRule{{Name: "a", Metric: "a", Hysteresis: -1}}},
+		{Handler: handler, Interval: -time.Second},
+	} {
+		_, err := NewAlerter(New(), params)
+		assert.NotNil(t, err, params)
+	}
+}
+
+func TestAlerterStart(t *testing.T) {
+	metrics := New()
+	metrics.Get("queue_len").Set(10)
+
+	events := make(chan AlertEvent, 1)
+	a, err := NewAlerter(metrics, AlertParams{
+		Rules:    []AlertRule{{Name: "queue", Metric: "queue_len", Threshold: 1}},
+		Handler:  func(e AlertEvent) { events <- e },
+		Interval: 10 * time.Millisecond,
+	})
+	require.Nil(t, err)
+
+	stopper, err := a.Start()
+	require.Nil(t, err)
+	defer stopper.Stop()
+
+	select {
+	case e := <-events:
+		assert.Equal(t, AlertFiring, e.State)
+	case <-time.After(time.Second):
+		t.Fatal("alert isn't fired")
+	}
+}
+
+func TestAlerterStartNoInterval(t *testing.T) {
+	a, err := NewAlerter(New(), AlertParams{Handler: func(AlertEvent) {}})
+	require.Nil(t, err)
+
+	_, err = a.Start()
+	assert.NotNil(t, err)
+}
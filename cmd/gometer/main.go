@@ -0,0 +1,341 @@
+// Command gometer inspects metrics files written by gometer.
+//
+// Usage:
+//
+//	gometer print [-sep SEP] [-match GLOB] FILE
+//	gometer filter [-sep SEP] GLOB FILE
+//	gometer convert [-sep SEP] [-match GLOB] -to FORMAT [-to-sep SEP] [-measurement NAME] FILE
+//	gometer watch [-sep SEP] [-match GLOB] [-interval DURATION] FILE
+//
+// FILE is a file written by the default or JSON formatter, "-" means stdin.
+// SEP is a line separator of the default formatter, "\n" by default.
+// FORMAT is one of text, json, prometheus, openmetrics or influx.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dshil/gometer"
+	"github.com/gobwas/glob"
+)
+
+const usage = `Usage:
+  gometer print [-sep SEP] [-match GLOB] FILE
+  gometer filter [-sep SEP] GLOB FILE
+  gometer convert [-sep SEP] [-match GLOB] -to FORMAT [-to-sep SEP] [-measurement NAME] FILE
+  gometer watch [-sep SEP] [-match GLOB] [-interval DURATION] FILE
+
+FORMAT is one of text, json, prometheus, openmetrics or influx.
+`
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if err == errUsage {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "gometer:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "print":
+		return runPrint(args, stdin, stdout, stderr)
+	case "filter":
+		return runFilter(args, stdin, stdout, stderr)
+	case "convert":
+		return runConvert(args, stdin, stdout, stderr)
+	case "watch":
+		return runWatch(args, stdout, stderr, nil)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	default:
+		return errUsage
+	}
+}
+
+// inputFlags are flags common for all subcommands.
+type inputFlags struct {
+	sep   string
+	match string
+}
+
+func newFlagSet(name string, stderr io.Writer, in *inputFlags, withMatch bool) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&in.sep, "sep", `\n`, "line separator of the default formatter")
+	if withMatch {
+		fs.StringVar(&in.match, "match", "", "glob pattern of metric names to show")
+	}
+	return fs
+}
+
+func (in *inputFlags) separator() (string, error) {
+	return unquote(in.sep)
+}
+
+// unquote interprets escape sequences of a separator given in a command line.
+func unquote(s string) (string, error) {
+	v, err := strconv.Unquote(`"` + s + `"`)
+	if err != nil {
+		return "", fmt.Errorf("invalid separator %q", s)
+	}
+	return v, nil
+}
+
+func readFile(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(stdin)
+	}
+	return ioutil.ReadFile(path)
+}
+
+func (in *inputFlags) load(path string, stdin io.Reader) (gometer.Snapshot, error) {
+	data, err := readFile(path, stdin)
+	if err != nil {
+		return gometer.Snapshot{}, err
+	}
+
+	sep, err := in.separator()
+	if err != nil {
+		return gometer.Snapshot{}, err
+	}
+	s, err := gometer.ParseAny(data, sep)
+	if err != nil {
+		return gometer.Snapshot{}, err
+	}
+
+	if in.match != "" {
+		if s.Counters, err = filterCounters(s.Counters, in.match); err != nil {
+			return gometer.Snapshot{}, err
+		}
+	}
+	return s, nil
+}
+
+func filterCounters(counters gometer.SortedCounters, pattern string) (gometer.SortedCounters, error) {
+	g, err := glob.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %v", pattern, err)
+	}
+
+	var filtered gometer.SortedCounters
+	for _, c := range counters {
+		if g.Match(c.Name) {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered, nil
+}
+
+func runPrint(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var in inputFlags
+	fs := newFlagSet("print", stderr, &in, true)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	s, err := in.load(fs.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVALUE")
+	for _, c := range s.Counters {
+		fmt.Fprintf(w, "%s\t%s\n", c.Name, formatValue(c.Value()))
+	}
+	return w.Flush()
+}
+
+func runFilter(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var in inputFlags
+	fs := newFlagSet("filter", stderr, &in, false)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 2 {
+		return errUsage
+	}
+
+	data, err := readFile(fs.Arg(1), stdin)
+	if err != nil {
+		return err
+	}
+	sep, err := in.separator()
+	if err != nil {
+		return err
+	}
+	s, err := gometer.ParseAny(data, sep)
+	if err != nil {
+		return err
+	}
+	counters, err := filterCounters(s.Counters, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// the output format is the same as the input one.
+	f := gometer.NewFormatter(sep)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		f = jsonFormatter{}
+	}
+	_, err = stdout.Write(f.Format(counters))
+	return err
+}
+
+func runConvert(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var (
+		in          inputFlags
+		to, toSep   string
+		measurement string
+	)
+	fs := newFlagSet("convert", stderr, &in, true)
+	fs.StringVar(&to, "to", "", "output format: text, json, prometheus, openmetrics or influx")
+	fs.StringVar(&toSep, "to-sep", `\n`, "line separator of the text output format")
+	fs.StringVar(&measurement, "measurement", "gometer", "measurement of the influx output format")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+
+	var f gometer.Formatter
+	switch to {
+	case "text":
+		sep, err := unquote(toSep)
+		if err != nil {
+			return err
+		}
+		f = gometer.NewFormatter(sep)
+	case "json":
+		f = jsonFormatter{}
+	case "prometheus":
+		f = gometer.NewPrometheusFormatter()
+	case "openmetrics":
+		f = gometer.NewOpenMetricsFormatter(gometer.OpenMetricsParams{})
+	case "influx":
+		f = gometer.NewInfluxFormatter(measurement)
+	default:
+		return fmt.Errorf("unknown output format %q", to)
+	}
+
+	s, err := in.load(fs.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(f.Format(s.Counters))
+	return err
+}
+
+// runWatch prints a file every interval with per-second deltas between refreshes.
+// It stops when stopCh is closed, or never if stopCh is nil.
+func runWatch(args []string, stdout, stderr io.Writer, stopCh <-chan struct{}) error {
+	var (
+		in       inputFlags
+		interval time.Duration
+	)
+	fs := newFlagSet("watch", stderr, &in, true)
+	fs.DurationVar(&interval, "interval", time.Second, "refresh interval")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 || interval <= 0 {
+		return errUsage
+	}
+	path := fs.Arg(0)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev *gometer.Snapshot
+	for {
+		s, err := loadWatched(&in, path)
+		if err != nil {
+			// the file may be absent until the first write.
+			fmt.Fprintln(stderr, "gometer:", err)
+		} else if prev == nil || !s.Time.Equal(prev.Time) {
+			if err := printWatch(stdout, prev, s); err != nil {
+				return err
+			}
+			prev = &s
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return nil
+		}
+	}
+}
+
+// loadWatched loads a file using its modification time as the snapshot time.
+func loadWatched(in *inputFlags, path string) (gometer.Snapshot, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return gometer.Snapshot{}, err
+	}
+	s, err := in.load(path, nil)
+	if err != nil {
+		return gometer.Snapshot{}, err
+	}
+	s.Time = info.ModTime()
+	return s, nil
+}
+
+func printWatch(stdout io.Writer, prev *gometer.Snapshot, s gometer.Snapshot) error {
+	rates := make(map[string]string)
+	if prev != nil {
+		for _, c := range gometer.Diff(*prev, s, gometer.DiffParams{}).Changes {
+			rates[c.Name] = formatValue(c.Rate)
+		}
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "# %s\n", s.Time.Format(time.RFC3339))
+	fmt.Fprintln(w, "NAME\tVALUE\tDELTA/S")
+	for _, c := range s.Counters {
+		rate, ok := rates[c.Name]
+		if !ok {
+			rate = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, formatValue(c.Value()), rate)
+	}
+	fmt.Fprintln(w)
+	return w.Flush()
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// jsonFormatter formats metrics as a JSON map terminated by a newline.
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(counters gometer.SortedCounters) []byte {
+	data := gometer.NewJSONFormatter(gometer.JSONFormatterParams{}).Format(counters)
+	return append(data, '\n')
+}
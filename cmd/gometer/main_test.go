@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runCmd(t *testing.T, stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	err := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestPrint(t *testing.T) {
+	out, err := runCmd(t, "b = 0.5\na = 10\nabc = 3\n", "print", "-match", "a*", "-")
+	require.Nil(t, err)
+	assert.Equal(t, "NAME  VALUE\na     10\nabc   3\n", out)
+}
+
+func TestFilter(t *testing.T) {
+	out, err := runCmd(t, "a.x = 1;b.x = 2;a.y = 3;", "filter", "-sep", ";", "a.*", "-")
+	require.Nil(t, err)
+	assert.Equal(t, "a.x = 1;a.y = 3;", out)
+
+	out, err = runCmd(t, `{"a.x": 1, "b.x": 2}`, "filter", "*.x", "-")
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"a.x": 1, "b.x": 2}`, out)
+
+	_, err = runCmd(t, "a = 1\n", "filter", "[", "-")
+	assert.NotNil(t, err)
+}
+
+func TestConvert(t *testing.T) {
+	for _, tCase := range []struct {
+		args     []string
+		expected string
+	}{
+		{
+			args:     []string{"-to", "text", "-to-sep", ","},
+			expected: "a = 1,b = 0.5,",
+		},
+		{
+			args:     []string{"-to", "json"},
+			expected: `{"a":1,"b":0.5}` + "\n",
+		},
+		{
+			args:     []string{"-to", "prometheus", "-match", "a"},
+			expected: "# TYPE a untyped\na 1\n",
+		},
+		{
+			args:     []string{"-to", "openmetrics", "-match", "b"},
+			expected: "# TYPE b gauge\nb 0.5\n# EOF\n",
+		},
+		{
+			args:     []string{"-to", "influx", "-measurement", "app"},
+			expected: "app a=1i,b=0.5\n",
+		},
+	} {
+		args := append(append([]string{"convert"}, tCase.args...), "-")
+		out, err := runCmd(t, "a = 1\nb = 0.5\n", args...)
+		require.Nil(t, err, tCase.args)
+		assert.Equal(t, tCase.expected, out, tCase.args)
+	}
+
+	_, err := runCmd(t, "a = 1\n", "convert", "-to", "xml", "-")
+	assert.NotNil(t, err)
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"unknown"},
+		{"print"},
+		{"filter", "*"},
+		{"convert", "-unknown-flag", "-"},
+	} {
+		_, err := runCmd(t, "", args...)
+		assert.Equal(t, errUsage, err, args)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gometer")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "metrics")
+	now := time.Now()
+	require.Nil(t, ioutil.WriteFile(path, []byte("a = 10\n"), 0644))
+	require.Nil(t, os.Chtimes(path, now, now))
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	var stdout, stderr bytes.Buffer
+	go func() {
+		defer close(doneCh)
+		assert.Nil(t, runWatch([]string{"-interval", "10ms", path}, &stdout, &stderr, stopCh))
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	require.Nil(t, ioutil.WriteFile(path, []byte("a = 30\n"), 0644))
+	require.Nil(t, os.Chtimes(path, now.Add(2*time.Second), now.Add(2*time.Second)))
+	time.Sleep(50 * time.Millisecond)
+
+	close(stopCh)
+	<-doneCh
+
+	out := stdout.String()
+	assert.Contains(t, out, "a     10     -\n")
+	assert.Contains(t, out, "a     30     10\n")
+	assert.Equal(t, 2, strings.Count(out, "NAME"))
+}
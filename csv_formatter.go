@@ -0,0 +1,231 @@
+package gometer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"sync"
+	"time"
+)
+
+// CSVMode determines a layout of a CSV formatter.
+type CSVMode int
+
+const (
+	// CSVLong writes one "timestamp,name,value" row per metric.
+	CSVLong CSVMode = iota
+	// CSVWide writes one row per call of Format with a column per metric.
+	CSVWide
+)
+
+// CSVParams represents params of a CSV formatter.
+//
+// Comma is a field delimiter, ',' is used if it's zero, use '\t' for TSV.
+// TimeFormat is a layout of the timestamp column, time.RFC3339 is used if it's empty.
+// Now is used to get the timestamp, time.Now is used if it's nil.
+// NoHeader disables writing of headers.
+//
+// Columns fixes the columns of the wide mode: other metrics are skipped and
+// missing ones are empty. If it's empty, columns are the names of metrics
+// passed to the first call of Format and metrics that appear later are added
+// as new columns. The extended header is written again before the row, and a file
+// writer appending to a file rewrites the header of the file instead, padding
+// earlier rows with empty fields, so every row matches the header.
+type CSVParams struct {
+	Mode       CSVMode
+	Comma      rune
+	TimeFormat string
+	Now        func() time.Time
+	NoHeader   bool
+	Columns    []string
+}
+
+// NewCSVFormatter returns a formatter that writes metrics as CSV rows.
+//
+// The formatter keeps state between calls: the header is written only
+// by the first call of Format, so the output is intended to be appended
+// to a file (see FileWriterParams.Append). Use a separate formatter for
+// every destination. When a file writer appends to a file that already starts
+// with a header, e.g. written by a previous run, the header isn't written again
+// and in the wide mode columns of the existing header are used and extended.
+func NewCSVFormatter(params CSVParams) Formatter {
+	if params.Comma == 0 {
+		params.Comma = ','
+	}
+	if params.TimeFormat == "" {
+		params.TimeFormat = time.RFC3339
+	}
+	if params.Now == nil {
+		params.Now = time.Now
+	}
+
+	f := &csvFormatter{
+		params:  params,
+		columns: append([]string(nil), params.Columns...),
+		indexes: make(map[string]int),
+	}
+	for i, name := range f.columns {
+		f.indexes[name] = i
+	}
+	return f
+}
+
+type csvFormatter struct {
+	params CSVParams
+
+	mu            sync.Mutex
+	started       bool
+	headerWritten bool
+	columns       []string
+	indexes       map[string]int
+	// appending is set while the output is appended to a file,
+	// extended is set if the header of the file has to be rewritten.
+	appending bool
+	extended  bool
+}
+
+var (
+	_ Formatter       = (*csvFormatter)(nil)
+	_ headerFormatter = (*csvFormatter)(nil)
+)
+
+// resumeHeader adopts a header of a file that is going to be appended
+// if nothing is formatted yet.
+func (f *csvFormatter) resumeHeader(firstLine func() []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.appending = true
+	if f.started || f.params.NoHeader {
+		return
+	}
+	line := firstLine()
+	if len(line) == 0 {
+		return
+	}
+
+	r := csv.NewReader(bytes.NewReader(line))
+	r.Comma = f.params.Comma
+	header, err := r.Read()
+	if err != nil || len(header) == 0 || header[0] != "timestamp" {
+		return
+	}
+
+	if f.params.Mode == CSVWide {
+		f.columns = header[1:]
+		f.indexes = make(map[string]int, len(f.columns))
+		for i, name := range f.columns {
+			f.indexes[name] = i
+		}
+	} else if len(header) != 3 || header[1] != "name" || header[2] != "value" {
+		return
+	}
+	f.started = true
+	f.headerWritten = true
+}
+
+func (f *csvFormatter) Format(counters SortedCounters) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = f.params.Comma
+
+	ts := f.params.Now().Format(f.params.TimeFormat)
+	if f.params.Mode == CSVWide {
+		f.writeWide(w, ts, counters)
+	} else {
+		f.writeLong(w, ts, counters)
+	}
+	f.started = true
+	f.appending = false
+
+	w.Flush()
+	return buf.Bytes()
+}
+
+// headerExtended reports whether the header of the appended file has to be rewritten.
+func (f *csvFormatter) headerExtended() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.extended
+}
+
+// rewriteHeader replaces the header of data with the current one
+// and pads rows with empty fields up to the new number of columns.
+func (f *csvFormatter) rewriteHeader(data []byte) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = f.params.Comma
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]string{"timestamp"}, f.columns...)
+	if len(rows) == 0 {
+		rows = [][]string{header}
+	}
+	rows[0] = header
+	for i := 1; i < len(rows); i++ {
+		for len(rows[i]) < len(header) {
+			rows[i] = append(rows[i], "")
+		}
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = f.params.Comma
+	if err = w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	f.extended = false
+	return buf.Bytes(), nil
+}
+
+func (f *csvFormatter) writeLong(w *csv.Writer, ts string, counters SortedCounters) {
+	if !f.headerWritten && !f.params.NoHeader {
+		_ = w.Write([]string{"timestamp", "name", "value"})
+	}
+	f.headerWritten = true
+
+	for _, c := range counters {
+		_ = w.Write([]string{ts, c.Name, formatValue(c)})
+	}
+}
+
+func (f *csvFormatter) writeWide(w *csv.Writer, ts string, counters SortedCounters) {
+	extended := false
+	if len(f.params.Columns) == 0 {
+		for _, c := range counters {
+			if _, ok := f.indexes[c.Name]; !ok {
+				f.indexes[c.Name] = len(f.columns)
+				f.columns = append(f.columns, c.Name)
+				extended = true
+			}
+		}
+	}
+
+	switch {
+	case f.params.NoHeader:
+	case !f.headerWritten:
+		_ = w.Write(append([]string{"timestamp"}, f.columns...))
+	case extended && f.appending:
+		f.extended = true
+	case extended:
+		_ = w.Write(append([]string{"timestamp"}, f.columns...))
+	}
+	f.headerWritten = true
+
+	row := make([]string, 1+len(f.columns))
+	row[0] = ts
+	for _, c := range counters {
+		if i, ok := f.indexes[c.Name]; ok {
+			row[1+i] = formatValue(c)
+		}
+	}
+	_ = w.Write(row)
+}
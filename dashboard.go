@@ -0,0 +1,265 @@
+package gometer
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDashboardWindow  = 5 * time.Minute
+	defaultDashboardRefresh = 5 * time.Second
+
+	sparklineWidth  = 120
+	sparklineHeight = 20
+)
+
+// DashboardParams represents params of a dashboard.
+//
+// History is used for rates and sparklines, they aren't shown if it's nil.
+// Window is a time range of sparklines, 5 minutes by default.
+// Refresh is an interval of page updates, 5 seconds by default.
+type DashboardParams struct {
+	Title   string
+	History *History
+	Window  time.Duration
+	Refresh time.Duration
+}
+
+type dashboardRow struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Value     string `json:"value"`
+	Rate      string `json:"rate"`
+	Sparkline string `json:"sparkline"`
+}
+
+type dashboardGroup struct {
+	Name string
+	Rows []dashboardRow
+}
+
+type dashboardPage struct {
+	Title           string
+	Time            time.Time
+	Groups          []dashboardGroup
+	RefreshMillis   int64
+	SparklineWidth  int
+	SparklineHeight int
+}
+
+type dashboard struct {
+	m      Metrics
+	params DashboardParams
+}
+
+// NewDashboardHandler returns http.Handler that renders metrics of m as an HTML page.
+//
+// Metrics are grouped by the first segment of their names and can be
+// searched and sorted in a browser. The page updates itself using
+// the same handler with ?format=json query.
+func NewDashboardHandler(m Metrics, params DashboardParams) http.Handler {
+	if params.Title == "" {
+		params.Title = "gometer"
+	}
+	if params.Window <= 0 {
+		params.Window = defaultDashboardWindow
+	}
+	if params.Refresh <= 0 {
+		params.Refresh = defaultDashboardRefresh
+	}
+	return &dashboard{m: m, params: params}
+}
+
+func (d *dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := d.m.Snapshot()
+	rows := make([]dashboardRow, 0, len(s.Counters))
+	for _, e := range s.Counters {
+		rows = append(rows, d.row(s.Time, e))
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		if err := json.NewEncoder(w).Encode(rows); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	page := dashboardPage{
+		Title:           d.params.Title,
+		Time:            s.Time,
+		Groups:          groupDashboardRows(rows),
+		RefreshMillis:   int64(d.params.Refresh / time.Millisecond),
+		SparklineWidth:  sparklineWidth,
+		SparklineHeight: sparklineHeight,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (d *dashboard) row(now time.Time, e CounterEntry) dashboardRow {
+	row := dashboardRow{
+		Name:  e.Name,
+		Kind:  e.Kind.String(),
+		Value: formatValue(e),
+	}
+	if d.params.History == nil {
+		return row
+	}
+
+	points := d.params.History.Query(e.Name, now.Add(-d.params.Window), now)
+	row.Sparkline = sparkline(points, sparklineWidth, sparklineHeight)
+	if e.Kind != KindGauge && len(points) > 1 {
+		prev, cur := points[len(points)-2], points[len(points)-1]
+		delta := cur.Value - prev.Value
+		if e.Kind == KindMonotonic && delta < 0 {
+			delta = cur.Value
+		}
+		rate := delta / cur.Time.Sub(prev.Time).Seconds()
+		row.Rate = strconv.FormatFloat(rate, 'f', 2, 64) + "/s"
+	}
+	return row
+}
+
+// groupDashboardRows groups sorted rows by the first segment of their names.
+func groupDashboardRows(rows []dashboardRow) []dashboardGroup {
+	var groups []dashboardGroup
+	for _, row := range rows {
+		name := row.Name
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			name = name[:i]
+		}
+		if len(groups) == 0 || groups[len(groups)-1].Name != name {
+			groups = append(groups, dashboardGroup{Name: name})
+		}
+		g := &groups[len(groups)-1]
+		g.Rows = append(g.Rows, row)
+	}
+	return groups
+}
+
+// sparkline returns points of an SVG polyline fitted into width x height.
+// Non-finite values are skipped.
+func sparkline(points []Point, width, height int) string {
+	values := make([]float64, 0, len(points))
+	for _, p := range points {
+		if !math.IsNaN(p.Value) && !math.IsInf(p.Value, 0) {
+			values = append(values, p.Value)
+		}
+	}
+	if len(values) < 2 {
+		return ""
+	}
+
+	min, max := values[0], values[0]
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+
+	var b strings.Builder
+	step := float64(width) / float64(len(values)-1)
+	for i, v := range values {
+		y := float64(height) / 2
+		if max > min {
+			y = float64(height) - (v-min)/(max-min)*float64(height)
+		}
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%.1f,%.1f", float64(i)*step, y)
+	}
+	return b.String()
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+table { border-collapse: collapse; width: 100%; }
+th { cursor: pointer; text-align: left; border-bottom: 2px solid #ccc; }
+td, th { padding: 2px 8px; }
+td.num { font-family: monospace; text-align: right; }
+tr.group td { font-weight: bold; background: #eee; }
+polyline { fill: none; stroke: #36c; stroke-width: 1; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p><input id="search" type="search" placeholder="Search"> Updated <span id="time">{{.Time.Format "15:04:05"}}</span></p>
+<table>
+<thead><tr><th data-col="0">Name</th><th data-col="1">Kind</th><th data-col="2">Value</th><th data-col="3">Rate</th><th>History</th></tr></thead>
+{{range .Groups}}<tbody>
+<tr class="group"><td colspan="5">{{.Name}}</td></tr>
+{{range .Rows}}<tr class="metric" data-name="{{.Name}}"><td>{{.Name}}</td><td>{{.Kind}}</td><td class="num">{{.Value}}</td><td class="num">{{.Rate}}</td><td><svg width="{{$.SparklineWidth}}" height="{{$.SparklineHeight}}"><polyline points="{{.Sparkline}}"/></svg></td></tr>
+{{end}}</tbody>
+{{end}}</table>
+<script>
+(function() {
+  var search = document.getElementById('search');
+  function filter() {
+    var q = search.value.toLowerCase();
+    document.querySelectorAll('tr.metric').forEach(function(tr) {
+      tr.style.display = tr.dataset.name.toLowerCase().indexOf(q) < 0 ? 'none' : '';
+    });
+  }
+  search.addEventListener('input', filter);
+
+  var asc = {};
+  document.querySelectorAll('th[data-col]').forEach(function(th) {
+    th.addEventListener('click', function() {
+      var col = th.dataset.col;
+      asc[col] = !asc[col];
+      document.querySelectorAll('tbody').forEach(function(body) {
+        var rows = Array.prototype.slice.call(body.querySelectorAll('tr.metric'));
+        rows.sort(function(a, b) {
+          var x = a.cells[col].textContent, y = b.cells[col].textContent;
+          var nx = parseFloat(x), ny = parseFloat(y);
+          var c = (isNaN(nx) || isNaN(ny)) ? x.localeCompare(y) : nx - ny;
+          return asc[col] ? c : -c;
+        });
+        rows.forEach(function(tr) { body.appendChild(tr); });
+      });
+    });
+  });
+
+  function update() {
+    fetch(location.pathname + '?format=json').then(function(resp) {
+      return resp.json();
+    }).then(function(rows) {
+      var known = document.querySelectorAll('tr.metric');
+      if (known.length !== rows.length) {
+        location.reload();
+        return;
+      }
+      rows.forEach(function(row) {
+        var tr = document.querySelector('tr.metric[data-name="' + CSS.escape(row.name) + '"]');
+        if (!tr) {
+          location.reload();
+          return;
+        }
+        tr.cells[2].textContent = row.value;
+        tr.cells[3].textContent = row.rate;
+        tr.cells[4].querySelector('polyline').setAttribute('points', row.sparkline);
+      });
+      document.getElementById('time').textContent = new Date().toLocaleTimeString();
+    });
+  }
+  setInterval(update, {{.RefreshMillis}});
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,75 @@
+package gometer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	metrics := New()
+	requests := metrics.GetMonotonic("http.requests")
+	metrics.Get("http.in_flight").Set(2)
+	metrics.GaugeFunc("load", func() float64 { return 0.5 })
+
+	history, err := NewHistory(metrics, HistoryParams{
+		Tiers: []HistoryTier{{Interval: time.Second, Duration: time.Minute}},
+	})
+	require.Nil(t, err)
+	now := time.Now()
+	for i := 2; i > 0; i-- {
+		require.Nil(t, requests.Add(10))
+		s := metrics.Snapshot()
+		s.Time = now.Add(-time.Duration(i) * time.Second)
+		history.Record(s)
+	}
+
+	h := NewDashboardHandler(metrics, DashboardParams{Title: "<app>", History: history})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	page := w.Body.String()
+	assert.Contains(t, page, "<title>&lt;app&gt;</title>")
+	assert.Contains(t, page, `<tr class="group"><td colspan="5">http</td></tr>`)
+	assert.Contains(t, page, `<tr class="group"><td colspan="5">load</td></tr>`)
+	assert.Contains(t, page, `data-name="http.requests"`)
+	assert.Regexp(t, `setInterval\(update, +5000 *\)`, page)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics?format=json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var rows []dashboardRow
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &rows))
+	assert.Equal(t, []dashboardRow{
+		{Name: "http.in_flight", Kind: "counter", Value: "2", Rate: "0.00/s", Sparkline: "0.0,10.0 120.0,10.0"},
+		{Name: "http.requests", Kind: "monotonic", Value: "20", Rate: "10.00/s", Sparkline: "0.0,20.0 120.0,0.0"},
+		{Name: "load", Kind: "gauge", Value: "0.5", Sparkline: "0.0,10.0 120.0,10.0"},
+	}, rows)
+}
+
+func TestDashboardHandlerWithoutHistory(t *testing.T) {
+	metrics := New()
+	metrics.Get("counter").Set(1)
+
+	w := httptest.NewRecorder()
+	NewDashboardHandler(metrics, DashboardParams{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?format=json", nil))
+
+	var rows []dashboardRow
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &rows))
+	assert.Equal(t, []dashboardRow{{Name: "counter", Kind: "counter", Value: "1"}}, rows)
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Equal(t, "", sparkline(nil, 10, 10))
+	assert.Equal(t, "", sparkline([]Point{{Value: 1}}, 10, 10))
+	assert.Equal(t, "0.0,10.0 5.0,0.0 10.0,5.0", sparkline([]Point{{Value: 0}, {Value: 2}, {Value: 1}}, 10, 10))
+}
@@ -0,0 +1,35 @@
+package gometer
+
+import "sync"
+
+// deltaTracker converts snapshots of a periodic writer to increments
+// since its previous snapshot.
+//
+// Counters that appear for the first time are reported with their values,
+// i.e. as increments since zero. Gauges are reported as is.
+type deltaTracker struct {
+	mu   sync.Mutex
+	prev Snapshot
+}
+
+func (t *deltaTracker) next(counters SortedCounters) SortedCounters {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cur := Snapshot{Counters: counters}
+	deltas := make(map[string]CounterEntry)
+	for _, e := range Diff(t.prev, cur, DiffParams{}).Deltas() {
+		deltas[e.Name] = e
+	}
+	t.prev = cur
+
+	s := make(SortedCounters, 0, len(counters))
+	for _, e := range counters {
+		if d, ok := deltas[e.Name]; ok && e.Kind != KindGauge {
+			s = append(s, d)
+		} else {
+			s = append(s, e)
+		}
+	}
+	return s
+}
@@ -0,0 +1,29 @@
+package gometer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeltaTracker(t *testing.T) {
+	metrics := New()
+	requests := metrics.GetMonotonic("requests")
+	metrics.GaugeFunc("load", func() float64 { return 0.5 })
+	f := &jsonFormatter{}
+
+	// trackers of writers with different intervals.
+	fast, slow := &deltaTracker{}, &deltaTracker{}
+
+	_ = requests.Add(10)
+	assert.JSONEq(t, `{"load": 0.5, "requests": 10}`, string(f.Format(fast.next(metrics.Snapshot().Counters))))
+	assert.JSONEq(t, `{"load": 0.5, "requests": 10}`, string(f.Format(slow.next(metrics.Snapshot().Counters))))
+
+	_ = requests.Add(5)
+	assert.JSONEq(t, `{"load": 0.5, "requests": 5}`, string(f.Format(fast.next(metrics.Snapshot().Counters))))
+
+	_ = requests.Add(1)
+	metrics.Get("errors").Set(2)
+	assert.JSONEq(t, `{"errors": 2, "load": 0.5, "requests": 1}`, string(f.Format(fast.next(metrics.Snapshot().Counters))))
+	assert.JSONEq(t, `{"errors": 2, "load": 0.5, "requests": 6}`, string(f.Format(slow.next(metrics.Snapshot().Counters))))
+}
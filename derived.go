@@ -0,0 +1,280 @@
+package gometer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// exprNode is a node of a parsed expression of a derived metric.
+type exprNode interface {
+	eval(values map[string]float64) float64
+}
+
+type numberNode float64
+
+func (n numberNode) eval(map[string]float64) float64 {
+	return float64(n)
+}
+
+type nameNode string
+
+func (n nameNode) eval(values map[string]float64) float64 {
+	return values[string(n)]
+}
+
+type sumNode struct {
+	g glob.Glob
+}
+
+func (n sumNode) eval(values map[string]float64) float64 {
+	var sum float64
+	for name, v := range values {
+		if n.g.Match(name) {
+			sum += v
+		}
+	}
+	return sum
+}
+
+type negNode struct {
+	x exprNode
+}
+
+func (n negNode) eval(values map[string]float64) float64 {
+	return -n.x.eval(values)
+}
+
+type binaryNode struct {
+	op   byte
+	l, r exprNode
+}
+
+func (n binaryNode) eval(values map[string]float64) float64 {
+	l, r := n.l.eval(values), n.r.eval(values)
+	switch n.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	default:
+		return l / r
+	}
+}
+
+// exprParser is a recursive descent parser of expressions:
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/") factor }
+//	factor = number | name | "[" name "]" | "sum(" glob ")" | "(" expr ")" | "-" factor
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func parseExpr(s string) (exprNode, error) {
+	p := &exprParser{s: s}
+	n, err := p.expr()
+	if err != nil {
+		return nil, fmt.Errorf("gometer: invalid expression %q: %v", s, err)
+	}
+	if p.skipSpaces(); p.pos < len(p.s) {
+		return nil, fmt.Errorf("gometer: invalid expression %q: unexpected %q at %d", s, p.s[p.pos], p.pos)
+	}
+	return n, nil
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// consume skips spaces and the next byte if it's one of ops.
+func (p *exprParser) consume(ops string) (byte, bool) {
+	p.skipSpaces()
+	if p.pos < len(p.s) && strings.IndexByte(ops, p.s[p.pos]) >= 0 {
+		p.pos++
+		return p.s[p.pos-1], true
+	}
+	return 0, false
+}
+
+func (p *exprParser) expr() (exprNode, error) {
+	n, err := p.term()
+	for err == nil {
+		op, ok := p.consume("+-")
+		if !ok {
+			break
+		}
+		var r exprNode
+		if r, err = p.term(); err == nil {
+			n = binaryNode{op: op, l: n, r: r}
+		}
+	}
+	return n, err
+}
+
+func (p *exprParser) term() (exprNode, error) {
+	n, err := p.factor()
+	for err == nil {
+		op, ok := p.consume("*/")
+		if !ok {
+			break
+		}
+		var r exprNode
+		if r, err = p.factor(); err == nil {
+			n = binaryNode{op: op, l: n, r: r}
+		}
+	}
+	return n, err
+}
+
+func (p *exprParser) factor() (exprNode, error) {
+	if _, ok := p.consume("-"); ok {
+		x, err := p.factor()
+		return negNode{x: x}, err
+	}
+	if _, ok := p.consume("("); ok {
+		n, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.consume(")"); !ok {
+			return nil, fmt.Errorf("missing ) at %d", p.pos)
+		}
+		return n, nil
+	}
+	if _, ok := p.consume("["); ok {
+		end := strings.IndexByte(p.s[p.pos:], ']')
+		if end < 0 {
+			return nil, fmt.Errorf("missing ] at %d", p.pos)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("empty name at %d", p.pos)
+		}
+		name := p.s[p.pos : p.pos+end]
+		p.pos += end + 1
+		return nameNode(name), nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && isExprNameByte(p.s[p.pos]) {
+		p.pos++
+	}
+	token := p.s[start:p.pos]
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("operand expected at %d", start)
+	case token[0] >= '0' && token[0] <= '9' && !isExprName(token):
+		v, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", token)
+		}
+		return numberNode(v), nil
+	case token == "sum" && p.pos < len(p.s) && p.s[p.pos] == '(':
+		end := strings.IndexByte(p.s[p.pos:], ')')
+		if end < 0 {
+			return nil, fmt.Errorf("missing ) at %d", p.pos)
+		}
+		pattern := strings.TrimSpace(p.s[p.pos+1 : p.pos+end])
+		p.pos += end + 1
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %v", pattern, err)
+		}
+		return sumNode{g: g}, nil
+	default:
+		// "a-b" may be a name or a subtraction, so it's rejected.
+		if p.pos+1 < len(p.s) && p.s[p.pos] == '-' && isExprNameByte(p.s[p.pos+1]) {
+			return nil, fmt.Errorf("ambiguous - at %d, use spaces around operators or [name]", p.pos)
+		}
+		return nameNode(token), nil
+	}
+}
+
+// isExprName reports whether a token starting with a digit is a name, e.g. "5xx.count".
+func isExprName(token string) bool {
+	if _, err := strconv.ParseFloat(token, 64); err == nil {
+		return false
+	}
+	return strings.IndexFunc(token, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.')
+	}) >= 0
+}
+
+func isExprNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == ':'
+}
+
+// Derive registers a gauge computed by an arithmetic expression over other metrics.
+//
+// An expression consists of numbers, names of metrics, sum(glob) that sums
+// all metrics matching a glob pattern, operators + - * / and parentheses,
+// e.g. "cache.hits / (cache.hits + cache.misses)" or "sum(http.*.errors)".
+// Names may contain letters, digits and "_.:", other names and names that are
+// valid numbers are written in brackets, e.g. "[http-requests] / [1e3]".
+// A "-" between names without spaces, e.g. "a-b", is rejected as ambiguous.
+// Names are the ones used to register metrics, missing metrics are zeros and
+// derived metrics aren't visible to expressions. Expressions are evaluated
+// against the same values that are written, division by zero yields NaN or Inf.
+//
+// An error is returned if the expression is invalid or the name is already used
+// by a metric of another kind. Deriving the same name again replaces the expression.
+func (m *DefaultMetrics) Derive(name, expr string) error {
+	n, err := parseExpr(expr)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.derived[name]; !ok {
+		m.adoptRestoredLocked(name)
+		if err := m.nameConflictLocked(name); err != nil {
+			return err
+		}
+	}
+	m.derived[name] = n
+	return nil
+}
+
+// Derive registers a derived metric for standard metrics.
+// For more details see DefaultMetrics.Derive().
+func Derive(name, expr string) error {
+	return Default.Derive(name, expr)
+}
+
+// deriveLocked computes derived metrics from the values of collected metrics s.
+// It returns metrics of s accepted by predicate along with accepted derived metrics.
+func (m *DefaultMetrics) deriveLocked(s SortedCounters, predicate func(string) bool) SortedCounters {
+	if len(m.derived) == 0 {
+		return s
+	}
+
+	values := make(map[string]float64, len(s))
+	for _, e := range s {
+		values[e.Name] = e.Value()
+	}
+
+	if predicate != nil {
+		filtered := s[:0]
+		for _, e := range s {
+			if predicate(e.Name) {
+				filtered = append(filtered, e)
+			}
+		}
+		s = filtered
+	}
+	for k, n := range m.derived {
+		if predicate == nil || predicate(k) {
+			s = append(s, newGaugeEntry(k, n.eval(values)))
+		}
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package gometer
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpr(t *testing.T) {
+	values := map[string]float64{
+		"a":                 2,
+		"b.c":               3,
+		"http-requests":     6,
+		"5xx.count":         7,
+		"1e3":               8,
+		"http.users.errors": 4,
+		"http.items.errors": 5,
+		"http.items.count":  100,
+	}
+	for expr, expected := range map[string]float64{
+		"1":                          1,
+		"a + b.c * 2":                8,
+		"(a + b.c) * 2":              10,
+		"a - b.c - 1":                -2,
+		"12 / a / 2":                 3,
+		"-a + -(b.c)":                -5,
+		"missing + 1.5":              1.5,
+		"sum(http.*.errors)":         9,
+		"sum( http.* ) / a":          54.5,
+		"a/(a+b.c)":                  0.4,
+		"sum(http.{users,x}.errors)": 4,
+		"[http-requests] - [b.c]":    3,
+		"5xx.count / [1e3] - 1e3":    -999.125,
+		"2-1":                        1,
+	} {
+		n, err := parseExpr(expr)
+		require.Nil(t, err, expr)
+		assert.Equal(t, expected, n.eval(values), expr)
+	}
+
+	for _, expr := range []string{"", "a +", "(a", "a b", "sum(a", "sum([)", "1.2.3", "a $ b", ")", "http-requests", "a-1", "[a", "[]"} {
+		_, err := parseExpr(expr)
+		assert.NotNil(t, err, expr)
+	}
+}
+
+func TestDerive(t *testing.T) {
+	metrics := New()
+	metrics.SetRootPrefix("app.")
+	metrics.Get("cache.hits").Set(3)
+	metrics.GetMonotonic("cache.misses").Inc()
+	metrics.CounterFunc("http.users.errors", func() int64 { return 2 })
+	metrics.Get("http.items.errors").Set(5)
+
+	require.Nil(t, metrics.Derive("cache.hit_ratio", "cache.hits / (cache.hits + cache.misses)"))
+	require.Nil(t, metrics.Derive("http.errors", "sum(http.*.errors)"))
+	require.Nil(t, metrics.Derive("empty", "1 / missing"))
+	assert.NotNil(t, metrics.Derive("invalid", "a +"))
+
+	s := metrics.Snapshot()
+	ratio, ok := s.Get("app.cache.hit_ratio")
+	require.True(t, ok)
+	assert.Equal(t, KindGauge, ratio.Kind)
+	assert.Equal(t, 0.75, ratio.Value())
+	errors, _ := s.Get("app.http.errors")
+	assert.Equal(t, float64(7), errors.Value())
+	empty, _ := s.Get("app.empty")
+	assert.True(t, math.IsInf(empty.Value(), 1))
+	_, ok = s.Get("app.invalid")
+	assert.False(t, ok)
+
+	// derived metrics use all metrics even if only some of them are written.
+	assert.JSONEq(t, `{"app.http.errors": 7}`, string(metrics.GetJSON(func(name string) bool {
+		return name == "http.errors"
+	})))
+
+	var out bytes.Buffer
+	metrics.SetOutput(&out)
+	metrics.SetFormatter(NewPrometheusFormatter())
+	metrics.SetRootPrefix("")
+	require.Nil(t, metrics.Derive("empty", "cache.hits * 2"))
+	require.Nil(t, metrics.Write())
+	assert.Contains(t, out.String(), "# TYPE cache_hit_ratio gauge\ncache_hit_ratio 0.75\n")
+	assert.Contains(t, out.String(), "# TYPE empty gauge\nempty 6\n")
+
+	assert.Panics(t, func() { metrics.Get("http.errors") })
+	assert.NotNil(t, metrics.Derive("cache.hits", "1"))
+}
@@ -0,0 +1,125 @@
+package gometer
+
+import (
+	"math"
+	"time"
+)
+
+// DiffParams represents params of snapshots comparison.
+//
+// Monotonic reports whether a metric should be treated as a monotonic counter,
+// e.g. for snapshots parsed from files, where kinds of counters are lost.
+// Metrics of KindMonotonic are always treated as monotonic counters.
+type DiffParams struct {
+	Monotonic func(name string) bool
+}
+
+// MetricChange represents a change of a metric between two snapshots.
+//
+// Rate is a per-second rate of Delta, it's zero if the interval between
+// snapshots is unknown. Reset is set if a monotonic counter decreased,
+// in this case it's considered to be restarted from zero, so Delta is Cur.
+// IntDelta is Delta computed in integers, since Delta loses precision for counters
+// above 2^53. If either value is a gauge, it's Delta rounded to an integer.
+type MetricChange struct {
+	Name     string
+	Kind     Kind
+	Prev     float64
+	Cur      float64
+	Delta    float64
+	IntDelta int64
+	Rate     float64
+	Reset    bool
+}
+
+// SnapshotDiff represents changes of metrics between two snapshots.
+//
+// Changes contains metrics present in both snapshots, Added contains metrics
+// present only in the current one and Removed contains metrics present only
+// in the previous one. All of them are sorted by name.
+type SnapshotDiff struct {
+	Interval time.Duration
+	Changes  []MetricChange
+	Added    SortedCounters
+	Removed  SortedCounters
+}
+
+// Diff compares two snapshots of the same metrics.
+func Diff(prev, cur Snapshot, params DiffParams) SnapshotDiff {
+	d := SnapshotDiff{}
+	if !prev.Time.IsZero() && !cur.Time.IsZero() {
+		d.Interval = cur.Time.Sub(prev.Time)
+	}
+
+	// both snapshots are sorted by name, so they are merged.
+	i, j := 0, 0
+	for i < len(prev.Counters) || j < len(cur.Counters) {
+		switch {
+		case j == len(cur.Counters) || (i < len(prev.Counters) && prev.Counters[i].Name < cur.Counters[j].Name):
+			d.Removed = append(d.Removed, prev.Counters[i])
+			i++
+		case i == len(prev.Counters) || cur.Counters[j].Name < prev.Counters[i].Name:
+			d.Added = append(d.Added, cur.Counters[j])
+			j++
+		default:
+			d.Changes = append(d.Changes, d.change(prev.Counters[i], cur.Counters[j], params))
+			i++
+			j++
+		}
+	}
+	return d
+}
+
+func (d SnapshotDiff) change(prev, cur CounterEntry, params DiffParams) MetricChange {
+	c := MetricChange{
+		Name: cur.Name,
+		Kind: cur.Kind,
+		Prev: prev.Value(),
+		Cur:  cur.Value(),
+	}
+	if prev.Kind != KindGauge && cur.Kind != KindGauge {
+		c.IntDelta = cur.Counter.Get() - prev.Counter.Get()
+		c.Delta = float64(c.IntDelta)
+	} else {
+		c.Delta = c.Cur - c.Prev
+		if !math.IsNaN(c.Delta) && !math.IsInf(c.Delta, 0) {
+			c.IntDelta = int64(math.Round(c.Delta))
+		}
+	}
+
+	monotonic := cur.Kind == KindMonotonic || (params.Monotonic != nil && params.Monotonic(cur.Name))
+	if monotonic && (c.Delta < 0 || c.IntDelta < 0) {
+		c.Reset = true
+		c.Delta = c.Cur
+		c.IntDelta = cur.Counter.Get()
+	}
+	if d.Interval > 0 {
+		c.Rate = c.Delta / d.Interval.Seconds()
+	}
+	return c
+}
+
+// Deltas returns deltas of changed metrics renderable by any Formatter.
+// Deltas of gauges are gauges, deltas of counters are counters.
+func (d SnapshotDiff) Deltas() SortedCounters {
+	s := make(SortedCounters, 0, len(d.Changes))
+	for _, c := range d.Changes {
+		if c.Kind == KindGauge {
+			s = append(s, newGaugeEntry(c.Name, c.Delta))
+			continue
+		}
+		counter := &Counter{}
+		counter.Set(c.IntDelta)
+		s = append(s, CounterEntry{Name: c.Name, Counter: counter, Kind: c.Kind})
+	}
+	return s
+}
+
+// Rates returns per-second rates of changed metrics as gauges renderable by any Formatter.
+func (d SnapshotDiff) Rates() SortedCounters {
+	s := make(SortedCounters, 0, len(d.Changes))
+	for _, c := range d.Changes {
+		s = append(s, newGaugeEntry(c.Name, c.Rate))
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+package gometer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	metrics := New()
+	requests := metrics.GetMonotonic("requests")
+	queue := metrics.Get("queue")
+	removed := metrics.Get("removed")
+	load := 0.5
+	metrics.GaugeFunc("load", func() float64 { return load })
+
+	require.Nil(t, requests.Add(10))
+	queue.Set(5)
+	removed.Set(1)
+	prev := metrics.Snapshot()
+
+	require.Nil(t, requests.Add(20))
+	queue.Set(3)
+	load = 0.75
+	metrics.Get("added").Set(7)
+	cur := metrics.snapshot(func(name string) bool { return name != "removed" })
+	cur.Time = prev.Time.Add(2 * time.Second)
+
+	d := Diff(prev, cur, DiffParams{})
+	assert.Equal(t, 2*time.Second, d.Interval)
+	require.Len(t, d.Added, 1)
+	assert.Equal(t, "added", d.Added[0].Name)
+	require.Len(t, d.Removed, 1)
+	assert.Equal(t, "removed", d.Removed[0].Name)
+
+	assert.Equal(t, []MetricChange{
+		{Name: "load", Kind: KindGauge, Prev: 0.5, Cur: 0.75, Delta: 0.25, Rate: 0.125},
+		{Name: "queue", Kind: KindCounter, Prev: 5, Cur: 3, Delta: -2, IntDelta: -2, Rate: -1},
+		{Name: "requests", Kind: KindMonotonic, Prev: 10, Cur: 30, Delta: 20, IntDelta: 20, Rate: 10},
+	}, d.Changes)
+
+	f := &jsonFormatter{}
+	assert.JSONEq(t, `{"load": 0.25, "queue": -2, "requests": 20}`, string(f.Format(d.Deltas())))
+	assert.JSONEq(t, `{"load": 0.125, "queue": -1, "requests": 10}`, string(f.Format(d.Rates())))
+}
+
+func TestDiffReset(t *testing.T) {
+	prev, err := NewParser("\n").Parse([]byte("restarts = 100\nqueue = 10\n"))
+	require.Nil(t, err)
+	cur, err := NewParser("\n").Parse([]byte("restarts = 4\nqueue = 2\n"))
+	require.Nil(t, err)
+
+	// parsed snapshots have no time, so rates are unknown.
+	d := Diff(prev, cur, DiffParams{
+		Monotonic: func(name string) bool { return name == "restarts" },
+	})
+	assert.Equal(t, time.Duration(0), d.Interval)
+	assert.Equal(t, []MetricChange{
+		{Name: "queue", Kind: KindCounter, Prev: 10, Cur: 2, Delta: -8, IntDelta: -8},
+		{Name: "restarts", Kind: KindCounter, Prev: 100, Cur: 4, Delta: 4, IntDelta: 4, Reset: true},
+	}, d.Changes)
+}
+
+func TestDiffLargeCounters(t *testing.T) {
+	metrics := New()
+	bytes := metrics.GetMonotonic("bytes")
+	require.Nil(t, bytes.Add(1<<62))
+	prev := metrics.Snapshot()
+	require.Nil(t, bytes.Add(1))
+	cur := metrics.Snapshot()
+
+	// float64 can't represent 2^62+1, so integer deltas are used.
+	d := Diff(prev, cur, DiffParams{})
+	require.Len(t, d.Changes, 1)
+	assert.Equal(t, int64(1), d.Changes[0].IntDelta)
+	assert.Equal(t, float64(1), d.Changes[0].Delta)
+	assert.False(t, d.Changes[0].Reset)
+
+	deltas := d.Deltas()
+	require.Len(t, deltas, 1)
+	assert.Equal(t, int64(1), deltas[0].Counter.Get())
+}
@@ -1,5 +1,13 @@
 package gometer
 
+import "errors"
+
+// ErrNegativeDelta is returned when a negative value is added to a monotonic counter.
+var ErrNegativeDelta = errors.New("gometer: negative delta for monotonic counter")
+
+// ErrExemplarTooLong is returned when labels of an exemplar exceed the length allowed by OpenMetrics.
+var ErrExemplarTooLong = errors.New("gometer: exemplar labels are too long")
+
 // PanicHandler is used to handle errors that causing the panic.
 type PanicHandler interface {
 	Handle(err error)
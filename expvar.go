@@ -0,0 +1,125 @@
+package gometer
+
+import (
+	"expvar"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// NewExpvar returns expvar.Var that represents metrics of m which names start with prefix,
+// its String method returns the metrics as a JSON map, e.g. for /debug/vars:
+//
+//	expvar.Publish("gometer", gometer.NewExpvar(metrics, ""))
+func NewExpvar(m Metrics, prefix string) expvar.Var {
+	return &metricsVar{m: m, prefix: prefix}
+}
+
+type metricsVar struct {
+	m      Metrics
+	prefix string
+}
+
+var _ expvar.Var = (*metricsVar)(nil)
+
+func (v *metricsVar) String() string {
+	return string(v.m.GetJSON(func(name string) bool {
+		return strings.HasPrefix(name, v.prefix)
+	}))
+}
+
+// ExpvarImportParams represents params of importing expvar variables.
+//
+// Prefix is prepended to names of variables, "expvar." is used if it's empty.
+// Filter reports whether a variable should be imported, all numeric variables
+// are imported if it's nil.
+type ExpvarImportParams struct {
+	Prefix string
+	Filter func(name string) bool
+}
+
+// ImportExpvars mirrors published numeric expvar variables into metrics of m,
+// their values are read every time metrics are written or a snapshot is taken.
+//
+// expvar.Int variables are imported as counters of KindCounter, they aren't monotonic,
+// since they can be decreased or set. expvar.Float variables and expvar.Func variables
+// returning numbers are imported as gauges. Numeric values of expvar.Map variables are
+// imported with names joined by '.', e.g. "expvar.map.key".
+// Variables and keys of maps published after the call aren't imported,
+// variables created by NewExpvar are skipped.
+//
+// Importing the same variables again replaces their callbacks. A variable which name
+// is already used by a metric of another kind isn't imported, other variables are
+// imported and the first such error is returned.
+func ImportExpvars(m Metrics, params ExpvarImportParams) error {
+	if params.Prefix == "" {
+		params.Prefix = "expvar."
+	}
+
+	var firstErr error
+	expvar.Do(func(kv expvar.KeyValue) {
+		if params.Filter == nil || params.Filter(kv.Key) {
+			if err := importExpvar(m, params.Prefix+kv.Key, kv.Value); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+	return firstErr
+}
+
+func importExpvar(m Metrics, name string, v expvar.Var) (err error) {
+	// Metrics panics if the name is used by a metric of another kind.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("gometer: can't import expvar %q: %v", name, r)
+		}
+	}()
+
+	switch v := v.(type) {
+	case *expvar.Int:
+		m.CounterFunc(name, v.Value)
+	case *expvar.Float:
+		m.GaugeFunc(name, v.Value)
+	case expvar.Func:
+		if _, ok := expvarNumber(v.Value()); ok {
+			m.GaugeFunc(name, func() float64 {
+				val, ok := expvarNumber(v.Value())
+				if !ok {
+					return math.NaN()
+				}
+				return val
+			})
+		}
+	case *expvar.Map:
+		v.Do(func(kv expvar.KeyValue) {
+			if mapErr := importExpvar(m, name+"."+kv.Key, kv.Value); mapErr != nil && err == nil {
+				err = mapErr
+			}
+		})
+	}
+	return err
+}
+
+// expvarNumber converts a value returned by expvar.Func to a number.
+func expvarNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,122 @@
+package gometer
+
+import (
+	"expvar"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewExpvar(t *testing.T) {
+	metrics := New()
+	metrics.Get("http.requests").Set(10)
+	metrics.Get("db.queries").Set(3)
+	metrics.GaugeFunc("http.load", func() float64 { return 0.5 })
+
+	assert.JSONEq(t, `{"db.queries": 3, "http.load": 0.5, "http.requests": 10}`, NewExpvar(metrics, "").String())
+	assert.JSONEq(t, `{"http.load": 0.5, "http.requests": 10}`, NewExpvar(metrics, "http.").String())
+
+	// prefixes of root prefixed metrics are relative to the root.
+	metrics.SetRootPrefix("app.")
+	assert.JSONEq(t, `{"app.db.queries": 3}`, NewExpvar(metrics, "db.").String())
+}
+
+var (
+	publishTestExpvarsOnce sync.Once
+
+	testExpvarInt   *expvar.Int
+	testExpvarFloat *expvar.Float
+	testExpvarMap   *expvar.Map
+	testExpvarFunc  interface{}
+)
+
+// publishTestExpvars publishes variables once, since expvar panics on duplicates.
+func publishTestExpvars() {
+	publishTestExpvarsOnce.Do(func() {
+		testExpvarInt = expvar.NewInt("gometer_test.int")
+		testExpvarFloat = expvar.NewFloat("gometer_test.float")
+		testExpvarMap = expvar.NewMap("gometer_test.map")
+		expvar.Publish("gometer_test.func", expvar.Func(func() interface{} { return testExpvarFunc }))
+		expvar.Publish("gometer_test.string", expvar.Func(func() interface{} { return "text" }))
+		expvar.Publish("gometer_test.metrics", NewExpvar(New(), ""))
+	})
+}
+
+func TestImportExpvars(t *testing.T) {
+	publishTestExpvars()
+	testExpvarInt.Set(5)
+	testExpvarFloat.Set(0.25)
+	testExpvarMap.Init()
+	testExpvarMap.Add("hits", 2)
+	testExpvarMap.AddFloat("ratio", 0.5)
+	testExpvarFunc = 7
+
+	metrics := New()
+	params := ExpvarImportParams{
+		Filter: func(name string) bool { return strings.HasPrefix(name, "gometer_test.") },
+	}
+	require.Nil(t, ImportExpvars(metrics, params))
+	// importing again replaces callbacks.
+	require.Nil(t, ImportExpvars(metrics, params))
+
+	values := func() map[string]float64 {
+		values := make(map[string]float64)
+		for _, e := range metrics.Snapshot().Counters {
+			values[e.Name] = e.Value()
+		}
+		return values
+	}
+	assert.Equal(t, map[string]float64{
+		"expvar.gometer_test.int":       5,
+		"expvar.gometer_test.float":     0.25,
+		"expvar.gometer_test.map.hits":  2,
+		"expvar.gometer_test.map.ratio": 0.5,
+		"expvar.gometer_test.func":      7,
+	}, values())
+
+	// values are read on every snapshot.
+	testExpvarInt.Add(1)
+	testExpvarFunc = "not a number"
+	v := values()
+	assert.Equal(t, float64(6), v["expvar.gometer_test.int"])
+	assert.True(t, math.IsNaN(v["expvar.gometer_test.func"]))
+
+	metrics = New()
+	require.Nil(t, ImportExpvars(metrics, ExpvarImportParams{
+		Prefix: "vars/",
+		Filter: func(name string) bool { return name == "gometer_test.int" },
+	}))
+	s := metrics.Snapshot()
+	require.Len(t, s.Counters, 1)
+	assert.Equal(t, "vars/gometer_test.int", s.Counters[0].Name)
+	assert.Equal(t, KindCounter, s.Counters[0].Kind)
+}
+
+func TestImportExpvarsConflict(t *testing.T) {
+	publishTestExpvars()
+	testExpvarInt.Set(5)
+	testExpvarFloat.Set(0.25)
+
+	metrics := New()
+	metrics.Get("expvar.gometer_test.float").Set(1)
+
+	err := ImportExpvars(metrics, ExpvarImportParams{
+		Filter: func(name string) bool { return name == "gometer_test.int" || name == "gometer_test.float" },
+	})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "gometer_test.float")
+
+	// other variables are imported, the conflicting counter is kept.
+	values := make(map[string]float64)
+	for _, e := range metrics.Snapshot().Counters {
+		values[e.Name] = e.Value()
+	}
+	assert.Equal(t, map[string]float64{
+		"expvar.gometer_test.float": 1,
+		"expvar.gometer_test.int":   5,
+	}, values)
+}
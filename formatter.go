@@ -3,14 +3,82 @@ package gometer
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"strconv"
+	"time"
 )
 
-// SortedCounters represents counters slice sorted by name
-type SortedCounters []struct {
-	Name    string
-	Counter *Counter
+// Kind determines how a metric value changes over time.
+type Kind int
+
+const (
+	// KindCounter is an arbitrary counter that can be set to any value.
+	KindCounter Kind = iota
+	// KindMonotonic is a counter that can only grow, a decrease means a reset.
+	KindMonotonic
+	// KindGauge is a floating point value that can go up and down.
+	KindGauge
+)
+
+// String returns a human readable name of a kind.
+func (k Kind) String() string {
+	switch k {
+	case KindCounter:
+		return "counter"
+	case KindMonotonic:
+		return "monotonic"
+	case KindGauge:
+		return "gauge"
+	default:
+		return "unknown"
+	}
+}
+
+// CounterEntry represents a named counter.
+//
+// For KindGauge entries Counter holds the rounded value and Gauge holds the exact one.
+// For KindMonotonic entries Created holds the registration time of a counter
+// and Exemplar holds its latest exemplar if any.
+type CounterEntry struct {
+	Name     string
+	Counter  *Counter
+	Kind     Kind
+	Gauge    float64
+	Created  time.Time
+	Exemplar *Exemplar
+}
+
+// Value returns the exact value of an entry.
+func (e CounterEntry) Value() float64 {
+	if e.Kind == KindGauge {
+		return e.Gauge
+	}
+	return float64(e.Counter.Get())
 }
 
+// newGaugeEntry creates an entry for a gauge value.
+func newGaugeEntry(name string, val float64) CounterEntry {
+	c := &Counter{}
+	if !math.IsNaN(val) {
+		c.Set(int64(math.Round(val)))
+	}
+	return CounterEntry{Name: name, Counter: c, Kind: KindGauge, Gauge: val}
+}
+
+// formatValue returns a textual representation of an entry value.
+func formatValue(e CounterEntry) string {
+	if e.Kind == KindGauge {
+		return strconv.FormatFloat(e.Gauge, 'g', -1, 64)
+	}
+	return strconv.FormatInt(e.Counter.Get(), 10)
+}
+
+// SortedCounters represents counters slice sorted by name.
+//
+// Before v0.2.0 it was a slice of anonymous structs with Name and Counter fields,
+// see CHANGELOG.md for migration notes.
+type SortedCounters []CounterEntry
+
 // Formatter determines a format of metrics representation.
 type Formatter interface {
 	Format(counters SortedCounters) []byte
@@ -38,7 +106,7 @@ func (f *defaultFormatter) Format(counters SortedCounters) []byte {
 	var buf bytes.Buffer
 
 	for _, c := range counters {
-		fmt.Fprintf(&buf, "%s = %d%s", c.Name, c.Counter.Get(), f.lineSeparator)
+		fmt.Fprintf(&buf, "%s = %s%s", c.Name, formatValue(c), f.lineSeparator)
 	}
 
 	return buf.Bytes()
@@ -0,0 +1,307 @@
+package gometer
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCounters() SortedCounters {
+	metrics := New()
+	metrics.Get("http.errors").Set(-1)
+	_ = metrics.GetMonotonic("http requests").Add(10)
+	metrics.GaugeFunc("2xx,ratio=", func() float64 { return 0.5 })
+	return metrics.Snapshot().Counters
+}
+
+func TestPrometheusFormatter(t *testing.T) {
+	data := NewPrometheusFormatter().Format(newTestCounters())
+	assert.Equal(t, `# TYPE _2xx_ratio_ gauge
+_2xx_ratio_ 0.5
+# TYPE http_requests counter
+http_requests 10
+# TYPE http_errors untyped
+http_errors -1
+`, string(data))
+}
+
+func TestPrometheusFormatterCollisions(t *testing.T) {
+	metrics := New()
+	metrics.Get("a.b").Set(1)
+	metrics.Get("a_b").Set(2)
+	metrics.Get("a-b").Set(3)
+	metrics.Get("a_b_2").Set(4)
+
+	data := NewPrometheusFormatter().Format(metrics.Snapshot().Counters)
+	assert.Equal(t, `# TYPE a_b untyped
+a_b 3
+# TYPE a_b_3 untyped
+a_b_3 1
+# TYPE a_b_4 untyped
+a_b_4 2
+# TYPE a_b_2 untyped
+a_b_2 4
+`, string(data))
+}
+
+func TestInfluxFormatter(t *testing.T) {
+	data := NewInfluxFormatter("app metrics,v1").Format(newTestCounters())
+	assert.Equal(t, `app\ metrics\,v1 2xx\,ratio\==0.5,http\ requests=10i,http.errors=-1i`+"\n", string(data))
+
+	assert.Empty(t, NewInfluxFormatter("app").Format(nil))
+
+	// non-finite values are skipped.
+	metrics := New()
+	metrics.Get("hits").Set(0)
+	metrics.GaugeFunc("ratio", func() float64 { return math.NaN() })
+	metrics.GaugeFunc("speed", func() float64 { return math.Inf(1) })
+	data = NewInfluxFormatter("x").Format(metrics.Snapshot().Counters)
+	assert.Equal(t, "x hits=0i\n", string(data))
+
+	metrics = New()
+	metrics.GaugeFunc("ratio", func() float64 { return math.NaN() })
+	assert.Empty(t, NewInfluxFormatter("x").Format(metrics.Snapshot().Counters))
+}
+
+func TestNestedJSONFormatter(t *testing.T) {
+	metrics := New()
+	metrics.Get("svc.db.read.errors").Set(1)
+	metrics.Get("svc.db.read.count").Set(10)
+	metrics.Get("svc.db.write").Set(5)
+	metrics.Get("svc.db").Set(2)
+	metrics.GaugeFunc("svc.load", func() float64 { return 0.5 })
+	metrics.Get(`up"\`).Set(1)
+	counters := metrics.Snapshot().Counters
+
+	data := NewNestedJSONFormatter(NestedJSONParams{}).Format(counters)
+	assert.Equal(t, `{"svc":{"db":{"_value":2,"read":{"count":10,"errors":1},"write":5},"load":0.5},"up\"\\":1}`, string(data))
+
+	data = NewNestedJSONFormatter(NestedJSONParams{Subtotals: true}).Format(counters)
+	assert.JSONEq(t, `{
+		"svc": {
+			"_total": 18.5,
+			"db": {
+				"_value": 2,
+				"_total": 18,
+				"read": {"_total": 11, "count": 10, "errors": 1},
+				"write": 5
+			},
+			"load": 0.5
+		},
+		"up\"\\": 1
+	}`, string(data))
+
+	data = NewNestedJSONFormatter(NestedJSONParams{Separator: "/"}).Format(counters)
+	assert.JSONEq(t, `{"svc.db": 2, "svc.db.read.count": 10, "svc.db.read.errors": 1, "svc.db.write": 5, "svc.load": 0.5, "up\"\\": 1}`, string(data))
+
+	assert.Equal(t, "{}", string(NewNestedJSONFormatter(NestedJSONParams{}).Format(nil)))
+}
+
+func TestWriteJSONString(t *testing.T) {
+	for s, expected := range map[string]string{
+		"abc":          `"abc"`,
+		"a\"b\\c":      `"a\"b\\c"`,
+		"\n\r\t\x01":   `"\n\r\t\u0001"`,
+		"привет":       `"привет"`,
+		"bad\xffutf-8": `"bad�utf-8"`,
+	} {
+		var buf bytes.Buffer
+		writeJSONString(&buf, s)
+		assert.Equal(t, expected, buf.String(), s)
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	counters := newTestCounters()
+	counters = append(counters, newGaugeEntry("nan", math.NaN()), newGaugeEntry(`quote"\`, math.Inf(1)))
+
+	data := NewJSONFormatter(JSONFormatterParams{}).Format(counters)
+	assert.Equal(t, `{"2xx,ratio=":0.5,"http requests":10,"http.errors":-1,"nan":null,"quote\"\\":null}`, string(data))
+	assert.True(t, json.Valid(data))
+
+	data = NewJSONFormatter(JSONFormatterParams{Indent: "  "}).Format(counters[:2])
+	assert.Equal(t, "{\n  \"2xx,ratio=\": 0.5,\n  \"http requests\": 10\n}\n", string(data))
+
+	data = NewJSONFormatter(JSONFormatterParams{
+		Timestamp: true,
+		Metadata:  map[string]string{"host": "a", "app": "b"},
+		Now:       func() time.Time { return time.Unix(1, 5e6) },
+	}).Format(counters[:1])
+	assert.Equal(t, `{"ts":1005,"meta":{"app":"b","host":"a"},"metrics":{"2xx,ratio=":0.5}}`, string(data))
+
+	data = NewJSONFormatter(JSONFormatterParams{Metadata: map[string]string{"host": "a"}}).Format(nil)
+	assert.Equal(t, `{"meta":{"host":"a"},"metrics":{}}`, string(data))
+}
+
+func TestTemplateFormatter(t *testing.T) {
+	f, err := NewTemplateFormatter(`{{range .Counters -}}
+{{sanitize (trimPrefix "http" .Name)}}{{labels "kind" .Kind.String "last" (segment " " -1 .Name)}} {{value .}}
+{{end}}`)
+	require.Nil(t, err)
+	assert.Equal(t, `_2xx_ratio_{kind="gauge",last="2xx,ratio="} 0.5
+_requests{kind="monotonic",last="requests"} 10
+_errors{kind="counter",last="http.errors"} -1
+`, string(f.Format(newTestCounters())))
+
+	f, err = NewTemplateFormatter(`{{range .Counters}}{{quote .Name}} {{.Value}} {{unixMilli $.Time}}{{end}}`)
+	require.Nil(t, err)
+	assert.Regexp(t, `^"http.errors" -1 \d+$`, string(f.Format(newTestCounters()[2:])))
+
+	for _, text := range []string{
+		"{{range .Counters}}",
+		"{{.Unknown}}",
+		"{{unknown .}}",
+		`{{labels "a"}}`,
+	} {
+		_, err := NewTemplateFormatter(text)
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestTemplateHelpers(t *testing.T) {
+	assert.Equal(t, "b", templateSegment(".", 1, "a.b.c"))
+	assert.Equal(t, "c", templateSegment(".", -1, "a.b.c"))
+	assert.Equal(t, "", templateSegment(".", 3, "a.b.c"))
+
+	labels, err := templateLabels("path", `C:\ "привет"`+"\n", "a.b", "")
+	require.Nil(t, err)
+	assert.Equal(t, `{path="C:\\ \"привет\"\n",a_b=""}`, labels)
+	tags, err := templateTags("host name", "a,b=c", "dc", "eu")
+	require.Nil(t, err)
+	assert.Equal(t, `,host\ name=a\,b\=c,dc=eu`, tags)
+	_, err = templateTags("odd")
+	assert.NotNil(t, err)
+
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, "2020-01-02T03:04:05Z", templateFuncs["rfc3339"].(func(time.Time) string)(ts))
+	assert.Equal(t, int64(1577934245), templateFuncs["unix"].(func(time.Time) int64)(ts))
+}
+
+func TestCSVFormatter(t *testing.T) {
+	now := func() time.Time { return time.Unix(1, 0).UTC() }
+
+	f := NewCSVFormatter(CSVParams{Now: now})
+	counters := newTestCounters()
+	assert.Equal(t, `timestamp,name,value
+1970-01-01T00:00:01Z,"2xx,ratio=",0.5
+1970-01-01T00:00:01Z,http requests,10
+1970-01-01T00:00:01Z,http.errors,-1
+`, string(f.Format(counters)))
+	assert.Equal(t, "1970-01-01T00:00:01Z,http.errors,-1\n", string(f.Format(counters[2:])))
+
+	// new metrics extend columns and the header is written again.
+	f = NewCSVFormatter(CSVParams{Mode: CSVWide, Comma: '\t', TimeFormat: "15:04:05", Now: now})
+	assert.Equal(t, "timestamp\thttp.errors\n00:00:01\t-1\n", string(f.Format(counters[2:])))
+	assert.Equal(t, "00:00:01\t\n", string(f.Format(nil)))
+	assert.Equal(t, "timestamp\thttp.errors\t2xx,ratio=\thttp requests\n00:00:01\t-1\t0.5\t10\n", string(f.Format(counters)))
+	assert.Equal(t, "00:00:01\t-1\t0.5\t10\n", string(f.Format(counters)))
+
+	f = NewCSVFormatter(CSVParams{Mode: CSVWide, Columns: []string{"http.errors", "missing"}, NoHeader: true, Now: now})
+	assert.Equal(t, "1970-01-01T00:00:01Z,-1,\n", string(f.Format(counters)))
+
+	// a header of an appended file is adopted and rewritten if it's extended.
+	f = NewCSVFormatter(CSVParams{Mode: CSVWide, Now: now})
+	h := f.(headerFormatter)
+	h.resumeHeader(func() []byte { return []byte("timestamp,http requests,missing\n") })
+	assert.Equal(t, "1970-01-01T00:00:01Z,10,,0.5,-1\n", string(f.Format(counters)))
+	require.True(t, h.headerExtended())
+	data, err := h.rewriteHeader([]byte("timestamp,http requests,missing\n0,9,1\n"))
+	require.Nil(t, err)
+	assert.Equal(t, "timestamp,http requests,missing,\"2xx,ratio=\",http.errors\n0,9,1,,\n", string(data))
+	assert.False(t, h.headerExtended())
+
+	f = NewCSVFormatter(CSVParams{Now: now})
+	f.(headerFormatter).resumeHeader(func() []byte { return []byte("timestamp,name,value\n") })
+	assert.Equal(t, "1970-01-01T00:00:01Z,http.errors,-1\n", string(f.Format(counters[2:])))
+
+	f = NewCSVFormatter(CSVParams{Now: now})
+	f.(headerFormatter).resumeHeader(func() []byte { return []byte("garbage\n") })
+	assert.Equal(t, "timestamp,name,value\n1970-01-01T00:00:01Z,http.errors,-1\n", string(f.Format(counters[2:])))
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	now := func() time.Time { return time.Unix(1, 0).UTC() }
+	counters := newTestCounters()
+
+	data := NewLogfmtFormatter(LogfmtParams{Now: now}).Format(counters)
+	assert.Equal(t, `ts=1970-01-01T00:00:01Z name="2xx,ratio=" value=0.5
+ts=1970-01-01T00:00:01Z name="http requests" value=10
+ts=1970-01-01T00:00:01Z name=http.errors value=-1
+`, string(data))
+
+	data = NewLogfmtFormatter(LogfmtParams{SingleLine: true, Now: now}).Format(counters)
+	assert.Equal(t, "ts=1970-01-01T00:00:01Z 2xx,ratio_=0.5 http_requests=10 http.errors=-1\n", string(data))
+
+	data = NewLogfmtFormatter(LogfmtParams{SingleLine: true, NoTimestamp: true}).Format(counters[2:])
+	assert.Equal(t, "http.errors=-1\n", string(data))
+	assert.Empty(t, NewLogfmtFormatter(LogfmtParams{SingleLine: true}).Format(nil))
+}
+
+func TestOpenMetricsFormatter(t *testing.T) {
+	metrics := New()
+	requests := metrics.GetMonotonic("http.requests_total")
+	require.Nil(t, requests.AddWithExemplar(2, map[string]string{"trace_id": `a"b`}))
+	metrics.GetMonotonic("sent_bytes").Inc()
+	metrics.Get("queue").Set(3)
+	metrics.GaugeFunc("request.duration", func() float64 { return 0.25 })
+
+	counters := metrics.Snapshot().Counters
+	for i := range counters {
+		if counters[i].Kind == KindMonotonic {
+			counters[i].Created = time.Unix(1, 5e8)
+		}
+		if counters[i].Exemplar != nil {
+			counters[i].Exemplar.Time = time.Unix(2, 0)
+		}
+	}
+
+	data := NewOpenMetricsFormatter(OpenMetricsParams{
+		Units: map[string]string{"request.duration": "seconds", "sent_bytes": "bytes"},
+	}).Format(counters)
+	assert.Equal(t, `# TYPE http_requests counter
+http_requests_total 2 # {trace_id="a\"b"} 2 2.000
+http_requests_created 1.500
+# TYPE queue unknown
+queue 3
+# TYPE request_duration_seconds gauge
+# UNIT request_duration_seconds seconds
+request_duration_seconds 0.25
+# TYPE sent_bytes counter
+# UNIT sent_bytes bytes
+sent_bytes_total 1
+sent_bytes_created 1.500
+# EOF
+`, string(data))
+
+	assert.Equal(t, "# EOF\n", string(NewOpenMetricsFormatter(OpenMetricsParams{}).Format(nil)))
+}
+
+func TestOpenMetricsFormatterCollisions(t *testing.T) {
+	metrics := New()
+	metrics.GetMonotonic("a").Inc()
+	metrics.Get("a.b").Set(1)
+	metrics.Get("a_b").Set(2)
+	metrics.Get("a_total").Set(3)
+
+	counters := metrics.Snapshot().Counters
+	counters[0].Created = time.Unix(1, 0)
+
+	// "a" occupies "a_total" and "a_created", so the gauge "a_total" gets a suffix.
+	data := NewOpenMetricsFormatter(OpenMetricsParams{}).Format(counters)
+	assert.Equal(t, `# TYPE a counter
+a_total 1
+a_created 1.000
+# TYPE a_b unknown
+a_b 1
+# TYPE a_b_2 unknown
+a_b_2 2
+# TYPE a_total_2 unknown
+a_total_2 3
+# EOF
+`, string(data))
+}
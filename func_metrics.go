@@ -0,0 +1,118 @@
+package gometer
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// defaultFuncTimeout is used when timeout of callback metrics isn't set.
+const defaultFuncTimeout = time.Second
+
+// collectorSampleTTL determines how long collectors reuse a sample.
+// All callbacks of one write are evaluated within this interval,
+// so a collector reads its source once per write.
+const collectorSampleTTL = 100 * time.Millisecond
+
+// funcMetric represents a metric whose value is computed by a callback.
+//
+// If a callback panics or doesn't return in time,
+// the last successfully computed value is used.
+// A callback isn't called again until its previous call returns.
+type funcMetric struct {
+	kind    Kind
+	counter func() int64
+	gauge   func() float64
+	last    uint64
+	running int32
+}
+
+func newCounterFunc(f func() int64) *funcMetric {
+	return &funcMetric{kind: KindCounter, counter: f}
+}
+
+func newMonotonicFunc(f func() int64) *funcMetric {
+	return &funcMetric{kind: KindMonotonic, counter: f}
+}
+
+func newGaugeFunc(f func() float64) *funcMetric {
+	return &funcMetric{kind: KindGauge, gauge: f}
+}
+
+// call calls the callback and stores its result as the last value.
+func (f *funcMetric) call() {
+	if f.kind == KindGauge {
+		atomic.StoreUint64(&f.last, math.Float64bits(f.gauge()))
+	} else {
+		atomic.StoreUint64(&f.last, uint64(f.counter()))
+	}
+}
+
+func (f *funcMetric) entry(name string) CounterEntry {
+	last := atomic.LoadUint64(&f.last)
+	if f.kind == KindGauge {
+		return newGaugeEntry(name, math.Float64frombits(last))
+	}
+	c := &Counter{}
+	c.Set(int64(last))
+	return CounterEntry{Name: name, Counter: c, Kind: f.kind}
+}
+
+// evalFuncMetrics calls all callbacks concurrently and waits for them no longer than timeout.
+// Callbacks that panic, time out or are still running since a previous call
+// are reported to errorHandler if it is set, their last values are kept.
+func evalFuncMetrics(funcs map[string]*funcMetric, timeout time.Duration, errorHandler func(err error)) {
+	if len(funcs) == 0 {
+		return
+	}
+
+	type result struct {
+		name string
+		err  error
+	}
+	resultCh := make(chan result, len(funcs))
+	pending := make(map[string]struct{}, len(funcs))
+
+	for name, f := range funcs {
+		if !atomic.CompareAndSwapInt32(&f.running, 0, 1) {
+			if errorHandler != nil {
+				errorHandler(fmt.Errorf("gometer: callback of %q is still running, the last value is used", name))
+			}
+			continue
+		}
+		pending[name] = struct{}{}
+
+		go func(name string, f *funcMetric) {
+			var err error
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("gometer: callback of %q panicked: %v", name, r)
+				}
+				atomic.StoreInt32(&f.running, 0)
+				resultCh <- result{name: name, err: err}
+			}()
+			f.call()
+		}(name, f)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for len(pending) > 0 {
+		select {
+		case r := <-resultCh:
+			delete(pending, r.name)
+			if r.err != nil && errorHandler != nil {
+				errorHandler(r.err)
+			}
+		case <-timer.C:
+			if errorHandler != nil {
+				for name := range pending {
+					errorHandler(fmt.Errorf("gometer: callback of %q timed out after %v", name, timeout))
+				}
+			}
+			return
+		}
+	}
+}
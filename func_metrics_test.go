@@ -0,0 +1,133 @@
+package gometer
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuncMetricsWrite(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	queue := []int{1, 2, 3}
+	metrics.CounterFunc("queue_len", func() int64 {
+		return int64(len(queue))
+	})
+	metrics.GaugeFunc("ratio", func() float64 {
+		return 0.25
+	})
+	metrics.GaugeFunc("self", func() float64 {
+		// callbacks are allowed to use metrics.
+		return float64(metrics.Get("counter").Get())
+	})
+	metrics.Get("counter").Set(7)
+
+	b := metrics.GetJSON(func(string) bool { return true })
+	assert.JSONEq(t, `{"counter": 7, "queue_len": 3, "ratio": 0.25, "self": 7}`, string(b))
+
+	queue = append(queue, 4)
+	s := metrics.Snapshot()
+	e, ok := s.Get("queue_len")
+	require.True(t, ok)
+	assert.Equal(t, int64(4), e.Counter.Get())
+
+	e, ok = s.Get("ratio")
+	require.True(t, ok)
+	assert.Equal(t, KindGauge, e.Kind)
+	assert.Equal(t, 0.25, e.Value())
+}
+
+func TestFuncMetricsPanic(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	var errs []error
+	metrics.SetFuncErrorHandler(func(err error) {
+		errs = append(errs, err)
+	})
+
+	fail := false
+	metrics.CounterFunc("value", func() int64 {
+		if fail {
+			panic("boom")
+		}
+		return 42
+	})
+
+	assert.JSONEq(t, `{"value": 42}`, string(metrics.GetJSON(func(string) bool { return true })))
+
+	fail = true
+	assert.JSONEq(t, `{"value": 42}`, string(metrics.GetJSON(func(string) bool { return true })))
+	assert.Len(t, errs, 1)
+}
+
+func TestFuncMetricsTimeout(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	metrics.SetFuncTimeout(time.Millisecond * 10)
+
+	errCh := make(chan error, 1)
+	metrics.SetFuncErrorHandler(func(err error) {
+		errCh <- err
+	})
+
+	unblockCh := make(chan struct{})
+	defer close(unblockCh)
+
+	metrics.GaugeFunc("slow", func() float64 {
+		<-unblockCh
+		return 1
+	})
+
+	s := metrics.Snapshot()
+	e, ok := s.Get("slow")
+	require.True(t, ok)
+	assert.Equal(t, float64(0), e.Value())
+	assert.NotNil(t, <-errCh)
+}
+
+func TestFuncMetricsKindConflict(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	metrics.Get("counter")
+	metrics.GaugeFunc("gauge", func() float64 { return 1 })
+	metrics.GaugeFunc("gauge", func() float64 { return 2 })
+
+	assert.Panics(t, func() { metrics.CounterFunc("counter", func() int64 { return 1 }) })
+	assert.Panics(t, func() { metrics.Get("gauge") })
+}
+
+func TestFuncMetricsNoGoroutineLeak(t *testing.T) {
+	metrics := New()
+	metrics.SetFuncTimeout(time.Millisecond)
+
+	var errs []error
+	metrics.SetFuncErrorHandler(func(err error) {
+		errs = append(errs, err)
+	})
+
+	unblockCh := make(chan struct{})
+	defer close(unblockCh)
+
+	metrics.CounterFunc("hung", func() int64 {
+		<-unblockCh
+		return 1
+	})
+
+	metrics.Snapshot()
+	n := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		metrics.Snapshot()
+	}
+	assert.True(t, runtime.NumGoroutine() <= n, "goroutines: %d, expected at most %d", runtime.NumGoroutine(), n)
+
+	// the first call timed out, next ones were skipped while it's still running.
+	assert.Len(t, errs, 11)
+	assert.Contains(t, errs[len(errs)-1].Error(), "still running")
+}
@@ -4,6 +4,6 @@ go 1.16
 
 require (
 	github.com/dchest/safefile v0.0.0-20151022103144-855e8d98f185
-	github.com/gobwas/glob v0.2.3 // indirect
+	github.com/gobwas/glob v0.2.3
 	github.com/stretchr/testify v1.7.0
 )
@@ -0,0 +1,235 @@
+package gometer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+// HistoryTier determines resolution and retention of recorded values.
+//
+// Interval is a resolution of a tier, all samples within one interval
+// are aggregated into one point: the last value is kept for counters and
+// the average value is kept for gauges. Duration is how long points are kept.
+type HistoryTier struct {
+	Interval time.Duration
+	Duration time.Duration
+}
+
+// DefaultHistoryTiers keeps 1s resolution for 5 minutes and 1m resolution for 24 hours.
+var DefaultHistoryTiers = []HistoryTier{
+	{Interval: time.Second, Duration: 5 * time.Minute},
+	{Interval: time.Minute, Duration: 24 * time.Hour},
+}
+
+// HistoryParams represents params of a history recorder.
+//
+// Tiers determine how values are stored, DefaultHistoryTiers are used if it's empty.
+type HistoryParams struct {
+	Tiers []HistoryTier
+}
+
+// Point represents a value of a metric at a moment of time.
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// History records values of metrics into fixed-size ring buffers.
+//
+// Memory used by a history is bounded by the number of metrics and tiers.
+// Series of removed metrics are kept until the history is recreated.
+type History struct {
+	m     Metrics
+	tiers []HistoryTier
+
+	mu     sync.Mutex
+	series map[string]*historySeries
+	latest time.Time
+}
+
+// NewHistory creates a history of m.
+//
+// An error is returned if a tier has non-positive interval or its duration
+// is shorter than the interval.
+func NewHistory(m Metrics, params HistoryParams) (*History, error) {
+	tiers := params.Tiers
+	if len(tiers) == 0 {
+		tiers = DefaultHistoryTiers
+	}
+	for _, tier := range tiers {
+		if tier.Interval <= 0 || tier.Duration < tier.Interval {
+			return nil, fmt.Errorf("gometer: invalid history tier: interval %v, duration %v", tier.Interval, tier.Duration)
+		}
+	}
+	tiers = append([]HistoryTier(nil), tiers...)
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].Interval < tiers[j].Interval
+	})
+
+	return &History{
+		m:      m,
+		tiers:  tiers,
+		series: make(map[string]*historySeries),
+	}, nil
+}
+
+// Start starts a goroutine that samples metrics with the resolution of the finest tier.
+func (h *History) Start() Stopper {
+	return startPeriodic(h.tiers[0].Interval, false, func() {
+		h.Record(h.m.Snapshot())
+	})
+}
+
+// Record adds values of a snapshot to the history.
+// Snapshots must be recorded in chronological order.
+func (h *History) Record(s Snapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, e := range s.Counters {
+		series, ok := h.series[e.Name]
+		if !ok {
+			series = newHistorySeries(h.tiers)
+			h.series[e.Name] = series
+		}
+		series.add(s.Time, e.Value(), e.Kind == KindGauge)
+	}
+	if s.Time.After(h.latest) {
+		h.latest = s.Time
+	}
+}
+
+// Names returns sorted names of recorded metrics.
+func (h *History) Names() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	names := make([]string, 0, len(h.series))
+	for name := range h.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Query returns points of a metric within [from, to] in chronological order.
+//
+// Points are taken from the finest tier which retention covers from.
+func (h *History) Query(name string, from, to time.Time) []Point {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	series, ok := h.series[name]
+	if !ok {
+		return nil
+	}
+	return series.rings[h.tierForLocked(from)].between(from, to)
+}
+
+// QueryGlob returns points of metrics which names match a glob pattern.
+// For more details see History.Query().
+func (h *History) QueryGlob(pattern string, from, to time.Time) (map[string][]Point, error) {
+	g, err := glob.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("gometer: invalid glob %q: %v", pattern, err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	tier := h.tierForLocked(from)
+	result := make(map[string][]Point)
+	for name, series := range h.series {
+		if g.Match(name) {
+			result[name] = series.rings[tier].between(from, to)
+		}
+	}
+	return result, nil
+}
+
+func (h *History) tierForLocked(from time.Time) int {
+	for i, tier := range h.tiers {
+		if !h.latest.Add(-tier.Duration).After(from) {
+			return i
+		}
+	}
+	return len(h.tiers) - 1
+}
+
+// historySeries represents values of one metric in all tiers.
+type historySeries struct {
+	rings []*historyRing
+}
+
+func newHistorySeries(tiers []HistoryTier) *historySeries {
+	s := &historySeries{rings: make([]*historyRing, len(tiers))}
+	for i, tier := range tiers {
+		size := int(tier.Duration / tier.Interval)
+		if size < 1 {
+			size = 1
+		}
+		s.rings[i] = &historyRing{
+			interval: tier.Interval,
+			points:   make([]Point, size),
+		}
+	}
+	return s
+}
+
+func (s *historySeries) add(t time.Time, v float64, average bool) {
+	for _, r := range s.rings {
+		r.add(t, v, average)
+	}
+}
+
+// historyRing is a fixed-size ring buffer of points with a fixed interval.
+// The last point represents an interval that may still be in progress.
+type historyRing struct {
+	interval time.Duration
+	points   []Point
+	start    int
+	n        int
+
+	// lastCount is a number of samples aggregated into the last point.
+	lastCount int
+}
+
+func (r *historyRing) add(t time.Time, v float64, average bool) {
+	bucket := t.Truncate(r.interval)
+
+	if r.n > 0 {
+		last := &r.points[(r.start+r.n-1)%len(r.points)]
+		if last.Time.Equal(bucket) {
+			if average {
+				r.lastCount++
+				last.Value += (v - last.Value) / float64(r.lastCount)
+			} else {
+				last.Value = v
+			}
+			return
+		}
+	}
+
+	if r.n < len(r.points) {
+		r.n++
+	} else {
+		r.start = (r.start + 1) % len(r.points)
+	}
+	r.points[(r.start+r.n-1)%len(r.points)] = Point{Time: bucket, Value: v}
+	r.lastCount = 1
+}
+
+func (r *historyRing) between(from, to time.Time) []Point {
+	var points []Point
+	for i := 0; i < r.n; i++ {
+		p := r.points[(r.start+i)%len(r.points)]
+		if !p.Time.Before(from.Truncate(r.interval)) && !p.Time.After(to) {
+			points = append(points, p)
+		}
+	}
+	return points
+}
@@ -0,0 +1,102 @@
+package gometer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryRecord(t *testing.T) {
+	metrics := New()
+	requests := metrics.GetMonotonic("http.requests")
+	errors := metrics.Get("http.errors")
+	load := 0.0
+	metrics.GaugeFunc("load", func() float64 { return load })
+
+	h, err := NewHistory(metrics, HistoryParams{
+		Tiers: []HistoryTier{
+			{Interval: time.Minute, Duration: 3 * time.Minute},
+			{Interval: time.Second, Duration: 3 * time.Second},
+		},
+	})
+	require.Nil(t, err)
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 240; i++ {
+		_ = requests.Add(1)
+		errors.Set(int64(i % 2))
+		load = float64(i % 60)
+
+		s := metrics.Snapshot()
+		s.Time = start.Add(time.Duration(i) * time.Second)
+		h.Record(s)
+	}
+	now := start.Add(239 * time.Second)
+
+	assert.Equal(t, []string{"http.errors", "http.requests", "load"}, h.Names())
+
+	// the last 3 seconds are kept with 1s resolution.
+	assert.Equal(t, []Point{
+		{Time: now.Add(-2 * time.Second), Value: 238},
+		{Time: now.Add(-time.Second), Value: 239},
+		{Time: now, Value: 240},
+	}, h.Query("http.requests", now.Add(-2*time.Second), now))
+
+	// older points are taken from the minute tier: the last value of counters
+	// and the average value of gauges within a minute.
+	from := start.Add(time.Minute)
+	assert.Equal(t, []Point{
+		{Time: start.Add(time.Minute), Value: 120},
+		{Time: start.Add(2 * time.Minute), Value: 180},
+		{Time: start.Add(3 * time.Minute), Value: 240},
+	}, h.Query("http.requests", from, now))
+	assert.Equal(t, []Point{
+		{Time: start.Add(time.Minute), Value: 29.5},
+		{Time: start.Add(2 * time.Minute), Value: 29.5},
+		{Time: start.Add(3 * time.Minute), Value: 29.5},
+	}, h.Query("load", from, now))
+
+	// the first minute is already evicted.
+	assert.Len(t, h.Query("http.requests", start, now), 3)
+	assert.Nil(t, h.Query("unknown", start, now))
+
+	points, err := h.QueryGlob("http.*", now, now)
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]Point{
+		"http.errors":   {{Time: now, Value: 1}},
+		"http.requests": {{Time: now, Value: 240}},
+	}, points)
+
+	_, err = h.QueryGlob("[", now, now)
+	assert.NotNil(t, err)
+}
+
+func TestHistoryStart(t *testing.T) {
+	metrics := New()
+	metrics.Get("counter").Set(7)
+
+	h, err := NewHistory(metrics, HistoryParams{
+		Tiers: []HistoryTier{{Interval: 10 * time.Millisecond, Duration: time.Second}},
+	})
+	require.Nil(t, err)
+	stopper := h.Start()
+	time.Sleep(50 * time.Millisecond)
+	stopper.Stop()
+
+	points := h.Query("counter", time.Now().Add(-time.Second), time.Now())
+	require.NotEmpty(t, points)
+	assert.Equal(t, float64(7), points[len(points)-1].Value)
+}
+
+func TestHistoryInvalidTiers(t *testing.T) {
+	for _, tier := range []HistoryTier{
+		{Interval: 0, Duration: time.Minute},
+		{Interval: -time.Second, Duration: time.Minute},
+		{Interval: time.Minute, Duration: time.Second},
+	} {
+		_, err := NewHistory(New(), HistoryParams{Tiers: []HistoryTier{tier}})
+		assert.NotNil(t, err, tier)
+	}
+}
@@ -0,0 +1,233 @@
+package gometer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// httpMetrics represents counters of one route.
+type httpMetrics struct {
+	requests   *MonotonicCounter
+	errors     *MonotonicCounter
+	inFlight   *Counter
+	status     [5]*MonotonicCounter
+	bytesIn    *MonotonicCounter
+	bytesOut   *MonotonicCounter
+	durationNS *MonotonicCounter
+}
+
+func newHTTPMetrics(m Metrics, route string, withErrors bool) *httpMetrics {
+	pm := m.WithPrefix("%s.", route)
+	hm := &httpMetrics{
+		requests:   pm.GetMonotonic("requests"),
+		inFlight:   pm.Get("in_flight"),
+		bytesIn:    pm.GetMonotonic("bytes_in"),
+		bytesOut:   pm.GetMonotonic("bytes_out"),
+		durationNS: pm.GetMonotonic("duration_ns"),
+	}
+	if withErrors {
+		hm.errors = pm.GetMonotonic("errors")
+	}
+	for i := range hm.status {
+		hm.status[i] = pm.GetMonotonic(fmt.Sprintf("status.%dxx", i+1))
+	}
+	return hm
+}
+
+func (hm *httpMetrics) observeStatus(code int) {
+	if class := code/100 - 1; class >= 0 && class < len(hm.status) {
+		hm.status[class].Inc()
+	}
+}
+
+// NewHTTPHandler returns http.Handler that records metrics of requests served by h.
+//
+// Metrics are recorded under route, that is supplied by the user
+// to keep the number of metrics independent of request paths:
+// <route>.requests, <route>.in_flight, <route>.status.1xx ... <route>.status.5xx,
+// <route>.bytes_in, <route>.bytes_out and <route>.duration_ns.
+//
+// Informational responses, e.g. 103 Early Hints, aren't recorded, a request is
+// recorded with the status of its final response. A request which handler panics
+// is recorded as 5xx, the panic is propagated.
+//
+// http.ResponseWriter passed to h implements http.Flusher and http.Hijacker
+// if the original one does, the original one is returned by its Unwrap method.
+func NewHTTPHandler(m Metrics, route string, h http.Handler) http.Handler {
+	hm := newHTTPMetrics(m, route, false)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		hm.requests.Inc()
+		hm.inFlight.Add(1)
+
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &httpCountingBody{ReadCloser: r.Body, counter: hm.bytesIn}
+		}
+
+		rw := &httpResponseWriter{ResponseWriter: w}
+		defer func() {
+			hm.inFlight.Add(-1)
+			_ = hm.durationNS.Add(int64(time.Since(start)))
+			_ = hm.bytesOut.Add(rw.bytes)
+
+			if r := recover(); r != nil {
+				hm.observeStatus(http.StatusInternalServerError)
+				panic(r)
+			}
+			if !rw.hijacked {
+				hm.observeStatus(rw.statusCode())
+			}
+		}()
+
+		h.ServeHTTP(wrapResponseWriter(rw), r)
+	})
+}
+
+// NewHTTPRoundTripper returns http.RoundTripper that records metrics of requests sent by rt.
+// If rt is nil, http.DefaultTransport is used.
+//
+// Metrics are recorded under route: <route>.requests, <route>.in_flight, <route>.errors,
+// <route>.status.1xx ... <route>.status.5xx, <route>.bytes_out (request bodies),
+// <route>.bytes_in (response bodies, as they are read) and <route>.duration_ns
+// (time until response headers are received).
+func NewHTTPRoundTripper(m Metrics, route string, rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &httpRoundTripper{
+		rt: rt,
+		hm: newHTTPMetrics(m, route, true),
+	}
+}
+
+type httpRoundTripper struct {
+	rt http.RoundTripper
+	hm *httpMetrics
+}
+
+func (t *httpRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	t.hm.requests.Inc()
+	t.hm.inFlight.Add(1)
+	defer t.hm.inFlight.Add(-1)
+
+	if r.Body != nil && r.Body != http.NoBody {
+		// RoundTripper must not modify the request, so a shallow copy is used.
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.Body = &httpCountingBody{ReadCloser: r.Body, counter: t.hm.bytesOut}
+		r = r2
+	}
+
+	resp, err := t.rt.RoundTrip(r)
+	_ = t.hm.durationNS.Add(int64(time.Since(start)))
+	if err != nil {
+		t.hm.errors.Inc()
+		return nil, err
+	}
+
+	t.hm.observeStatus(resp.StatusCode)
+	if resp.Body != nil {
+		resp.Body = &httpCountingBody{ReadCloser: resp.Body, counter: t.hm.bytesIn}
+	}
+	return resp, nil
+}
+
+type httpCountingBody struct {
+	io.ReadCloser
+	counter *MonotonicCounter
+}
+
+func (b *httpCountingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	_ = b.counter.Add(int64(n))
+	return n, err
+}
+
+// httpResponseWriter remembers status code and counts bytes written to a response.
+type httpResponseWriter struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	hijacked bool
+}
+
+func (w *httpResponseWriter) WriteHeader(code int) {
+	// informational responses may precede the final one.
+	if w.status == 0 && (code >= 200 || code == http.StatusSwitchingProtocols) {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *httpResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the original writer, e.g. for http.ResponseController.
+func (w *httpResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *httpResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *httpResponseWriter) flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *httpResponseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+type httpFlusher struct{ *httpResponseWriter }
+
+func (w httpFlusher) Flush() { w.flush() }
+
+type httpHijacker struct{ *httpResponseWriter }
+
+func (w httpHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+type httpFlusherHijacker struct{ *httpResponseWriter }
+
+func (w httpFlusherHijacker) Flush() { w.flush() }
+
+func (w httpFlusherHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+// wrapResponseWriter exposes the same optional interfaces as the original writer.
+func wrapResponseWriter(w *httpResponseWriter) http.ResponseWriter {
+	_, isFlusher := w.ResponseWriter.(http.Flusher)
+	_, isHijacker := w.ResponseWriter.(http.Hijacker)
+
+	switch {
+	case isFlusher && isHijacker:
+		return httpFlusherHijacker{w}
+	case isFlusher:
+		return httpFlusher{w}
+	case isHijacker:
+		return httpHijacker{w}
+	default:
+		return w
+	}
+}
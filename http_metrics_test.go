@@ -0,0 +1,133 @@
+package gometer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	h := NewHTTPHandler(metrics.WithPrefix("http."), "users", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(body) == 0 {
+			http.Error(w, "no body", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+
+	for _, body := range []string{"hello", "world", ""} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/42", strings.NewReader(body)))
+	}
+
+	pm := metrics.WithPrefix("http.users.")
+	assert.Equal(t, int64(3), pm.GetMonotonic("requests").Get())
+	assert.Equal(t, int64(2), pm.GetMonotonic("status.2xx").Get())
+	assert.Equal(t, int64(1), pm.GetMonotonic("status.4xx").Get())
+	assert.Equal(t, int64(10), pm.GetMonotonic("bytes_in").Get())
+	assert.Equal(t, int64(10+len("no body\n")), pm.GetMonotonic("bytes_out").Get())
+	assert.Equal(t, int64(0), pm.Get("in_flight").Get())
+	assert.True(t, pm.GetMonotonic("duration_ns").Get() > 0)
+}
+
+func TestHTTPHandlerStatus(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	h := NewHTTPHandler(metrics, "r", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hints":
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/panic":
+			panic("boom")
+		}
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hints", nil))
+	assert.Panics(t, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	})
+
+	pm := metrics.WithPrefix("r.")
+	assert.Equal(t, int64(0), pm.GetMonotonic("status.1xx").Get())
+	assert.Equal(t, int64(0), pm.GetMonotonic("status.2xx").Get())
+	assert.Equal(t, int64(2), pm.GetMonotonic("status.5xx").Get())
+	assert.Equal(t, int64(0), pm.Get("in_flight").Get())
+}
+
+func TestHTTPHandlerOptionalInterfaces(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	var (
+		isFlusher, isHijacker bool
+		unwrapped             http.ResponseWriter
+	)
+	h := NewHTTPHandler(metrics, "stream", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, isFlusher = w.(http.Flusher)
+		_, isHijacker = w.(http.Hijacker)
+		if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); ok {
+			unwrapped = u.Unwrap()
+		}
+	}))
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, isFlusher)
+	assert.False(t, isHijacker)
+	assert.Equal(t, recorder, unwrapped)
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.Nil(t, err)
+	require.Nil(t, resp.Body.Close())
+	assert.True(t, isFlusher)
+	assert.True(t, isHijacker)
+}
+
+func TestHTTPRoundTripper(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("pong"))
+	}))
+	defer srv.Close()
+
+	metrics := New()
+	client := &http.Client{Transport: NewHTTPRoundTripper(metrics, "api", nil)}
+
+	for _, path := range []string{"/ping", "/missing"} {
+		resp, err := client.Post(srv.URL+path, "text/plain", strings.NewReader("ping"))
+		require.Nil(t, err)
+		_, err = ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		require.Nil(t, resp.Body.Close())
+	}
+
+	_, err := client.Get("http://127.0.0.1:0/")
+	require.NotNil(t, err)
+
+	pm := metrics.WithPrefix("api.")
+	assert.Equal(t, int64(3), pm.GetMonotonic("requests").Get())
+	assert.Equal(t, int64(1), pm.GetMonotonic("errors").Get())
+	assert.Equal(t, int64(1), pm.GetMonotonic("status.2xx").Get())
+	assert.Equal(t, int64(1), pm.GetMonotonic("status.4xx").Get())
+	assert.Equal(t, int64(8), pm.GetMonotonic("bytes_out").Get())
+	assert.Equal(t, int64(len("pong")+len("404 page not found\n")), pm.GetMonotonic("bytes_in").Get())
+}
@@ -0,0 +1,60 @@
+package gometer
+
+import (
+	"bytes"
+	"math"
+	"strings"
+)
+
+// NewInfluxFormatter returns a formatter of the InfluxDB line protocol.
+//
+// All metrics are written as fields of one point of the measurement,
+// integer values are written with the 'i' suffix. NaN and infinite values aren't
+// supported by the line protocol, so they are skipped. The timestamp is omitted,
+// so the time of writing is used by InfluxDB.
+func NewInfluxFormatter(measurement string) Formatter {
+	return &influxFormatter{
+		measurement: measurement,
+	}
+}
+
+type influxFormatter struct {
+	measurement string
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+	influxFieldKeyEscaper    = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+)
+
+func (f *influxFormatter) Format(counters SortedCounters) []byte {
+	var buf bytes.Buffer
+	fields := 0
+	for _, c := range counters {
+		if c.Kind == KindGauge && (math.IsNaN(c.Gauge) || math.IsInf(c.Gauge, 0)) {
+			continue
+		}
+		if fields == 0 {
+			buf.WriteString(influxMeasurementEscaper.Replace(f.measurement))
+			buf.WriteByte(' ')
+		} else {
+			buf.WriteByte(',')
+		}
+		fields++
+
+		buf.WriteString(influxFieldKeyEscaper.Replace(c.Name))
+		buf.WriteByte('=')
+		buf.WriteString(formatValue(c))
+		if c.Kind != KindGauge {
+			buf.WriteByte('i')
+		}
+	}
+	// a point without fields is invalid.
+	if fields > 0 {
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+var _ Formatter = (*influxFormatter)(nil)
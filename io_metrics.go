@@ -0,0 +1,236 @@
+package gometer
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+// IOCounters represents counters of one direction of I/O.
+//
+// Bytes is increased by the number of transferred bytes, Ops by one on each call,
+// Errors by one on each failed call (io.EOF isn't an error). Nil counters are ignored.
+type IOCounters struct {
+	Bytes  *Counter
+	Ops    *Counter
+	Errors *Counter
+}
+
+func (c IOCounters) observe(n int64, err error) {
+	if c.Bytes != nil {
+		c.Bytes.Add(n)
+	}
+	if c.Ops != nil {
+		c.Ops.Add(1)
+	}
+	if c.Errors != nil && err != nil && err != io.EOF {
+		c.Errors.Add(1)
+	}
+}
+
+// ConnCounters represents counters of network connections.
+//
+// Opened is increased by one for each wrapped connection,
+// Active is increased by one when a connection is wrapped and decreased by one
+// when it's closed. Nil counters are ignored.
+type ConnCounters struct {
+	Read   IOCounters
+	Write  IOCounters
+	Opened *Counter
+	Active *Counter
+}
+
+// NewReader returns io.Reader that counts reads from r.
+// It implements io.WriterTo if r does.
+func NewReader(r io.Reader, c IOCounters) io.Reader {
+	cr := &countingReader{r: r, c: c}
+	if _, ok := r.(io.WriterTo); ok {
+		return countingReaderWriterTo{cr}
+	}
+	return cr
+}
+
+// NewWriter returns io.Writer that counts writes to w.
+// It implements io.ReaderFrom if w does.
+func NewWriter(w io.Writer, c IOCounters) io.Writer {
+	cw := &countingWriter{w: w, c: c}
+	if _, ok := w.(io.ReaderFrom); ok {
+		return countingWriterReaderFrom{cw}
+	}
+	return cw
+}
+
+// NewReadWriteCloser returns io.ReadWriteCloser that counts reads and writes of rwc.
+// It implements io.WriterTo and io.ReaderFrom if rwc does.
+func NewReadWriteCloser(rwc io.ReadWriteCloser, read, write IOCounters) io.ReadWriteCloser {
+	c := &countingReadWriteCloser{
+		countingReader: countingReader{r: rwc, c: read},
+		countingWriter: countingWriter{w: rwc, c: write},
+		Closer:         rwc,
+	}
+
+	_, isWriterTo := rwc.(io.WriterTo)
+	_, isReaderFrom := rwc.(io.ReaderFrom)
+	switch {
+	case isWriterTo && isReaderFrom:
+		return struct {
+			*countingReadWriteCloser
+			countingReaderWriterTo
+			countingWriterReaderFrom
+		}{c, countingReaderWriterTo{&c.countingReader}, countingWriterReaderFrom{&c.countingWriter}}
+	case isWriterTo:
+		return struct {
+			*countingReadWriteCloser
+			countingReaderWriterTo
+		}{c, countingReaderWriterTo{&c.countingReader}}
+	case isReaderFrom:
+		return struct {
+			*countingReadWriteCloser
+			countingWriterReaderFrom
+		}{c, countingWriterReaderFrom{&c.countingWriter}}
+	default:
+		return c
+	}
+}
+
+// NewConn returns net.Conn that counts reads and writes of conn.
+// It implements io.WriterTo and io.ReaderFrom if conn does.
+func NewConn(conn net.Conn, c ConnCounters) net.Conn {
+	if c.Opened != nil {
+		c.Opened.Add(1)
+	}
+	if c.Active != nil {
+		c.Active.Add(1)
+	}
+
+	cc := &countingConn{
+		Conn:           conn,
+		countingReader: countingReader{r: conn, c: c.Read},
+		countingWriter: countingWriter{w: conn, c: c.Write},
+		active:         c.Active,
+	}
+
+	_, isWriterTo := conn.(io.WriterTo)
+	_, isReaderFrom := conn.(io.ReaderFrom)
+	switch {
+	case isWriterTo && isReaderFrom:
+		return struct {
+			*countingConn
+			countingReaderWriterTo
+			countingWriterReaderFrom
+		}{cc, countingReaderWriterTo{&cc.countingReader}, countingWriterReaderFrom{&cc.countingWriter}}
+	case isWriterTo:
+		return struct {
+			*countingConn
+			countingReaderWriterTo
+		}{cc, countingReaderWriterTo{&cc.countingReader}}
+	case isReaderFrom:
+		return struct {
+			*countingConn
+			countingWriterReaderFrom
+		}{cc, countingWriterReaderFrom{&cc.countingWriter}}
+	default:
+		return cc
+	}
+}
+
+// NewListener returns net.Listener whose accepted connections are counted
+// as described in NewConn.
+func NewListener(l net.Listener, c ConnCounters) net.Listener {
+	return &countingListener{Listener: l, c: c}
+}
+
+type countingReader struct {
+	r io.Reader
+	c IOCounters
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.c.observe(int64(n), err)
+	return n, err
+}
+
+type countingReaderWriterTo struct {
+	*countingReader
+}
+
+func (r countingReaderWriterTo) WriteTo(w io.Writer) (int64, error) {
+	n, err := r.r.(io.WriterTo).WriteTo(w)
+	r.c.observe(n, err)
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	c IOCounters
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.c.observe(int64(n), err)
+	return n, err
+}
+
+type countingWriterReaderFrom struct {
+	*countingWriter
+}
+
+func (w countingWriterReaderFrom) ReadFrom(r io.Reader) (int64, error) {
+	n, err := w.w.(io.ReaderFrom).ReadFrom(r)
+	w.c.observe(n, err)
+	return n, err
+}
+
+type countingReadWriteCloser struct {
+	countingReader
+	countingWriter
+	io.Closer
+}
+
+func (c *countingReadWriteCloser) Read(p []byte) (int, error) {
+	return c.countingReader.Read(p)
+}
+
+func (c *countingReadWriteCloser) Write(p []byte) (int, error) {
+	return c.countingWriter.Write(p)
+}
+
+type countingConn struct {
+	net.Conn
+	countingReader
+	countingWriter
+
+	active    *Counter
+	closeOnce sync.Once
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	return c.countingReader.Read(p)
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	return c.countingWriter.Write(p)
+}
+
+func (c *countingConn) Close() error {
+	c.closeOnce.Do(func() {
+		if c.active != nil {
+			c.active.Add(-1)
+		}
+	})
+	return c.Conn.Close()
+}
+
+type countingListener struct {
+	net.Listener
+	c ConnCounters
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn, l.c), nil
+}
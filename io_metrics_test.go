@@ -0,0 +1,154 @@
+package gometer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIOCounters() IOCounters {
+	return IOCounters{Bytes: &Counter{}, Ops: &Counter{}, Errors: &Counter{}}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestIOReader(t *testing.T) {
+	c := newTestIOCounters()
+	r := NewReader(strings.NewReader("hello world"), c)
+
+	// strings.Reader implements io.WriterTo.
+	_, ok := r.(io.WriterTo)
+	require.True(t, ok)
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, r)
+	require.Nil(t, err)
+	assert.Equal(t, int64(11), n)
+	assert.Equal(t, int64(11), c.Bytes.Get())
+	assert.Equal(t, int64(1), c.Ops.Get())
+
+	r = NewReader(io.LimitReader(strings.NewReader("hello"), 3), c)
+	_, ok = r.(io.WriterTo)
+	require.False(t, ok)
+
+	data, err := ioutil.ReadAll(r)
+	require.Nil(t, err)
+	assert.Equal(t, "hel", string(data))
+	assert.Equal(t, int64(14), c.Bytes.Get())
+	assert.Equal(t, int64(0), c.Errors.Get())
+}
+
+func TestIOWriter(t *testing.T) {
+	c := newTestIOCounters()
+	var buf bytes.Buffer
+	w := NewWriter(&buf, c)
+
+	// bytes.Buffer implements io.ReaderFrom.
+	_, ok := w.(io.ReaderFrom)
+	require.True(t, ok)
+
+	_, err := io.Copy(w, io.LimitReader(strings.NewReader("hello world"), 5))
+	require.Nil(t, err)
+	_, err = w.Write([]byte("!"))
+	require.Nil(t, err)
+	assert.Equal(t, "hello!", buf.String())
+	assert.Equal(t, int64(6), c.Bytes.Get())
+	assert.Equal(t, int64(2), c.Ops.Get())
+
+	w = NewWriter(failingWriter{}, c)
+	_, ok = w.(io.ReaderFrom)
+	require.False(t, ok)
+	_, err = w.Write([]byte("data"))
+	require.NotNil(t, err)
+	assert.Equal(t, int64(1), c.Errors.Get())
+}
+
+func TestIOConn(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	server := ConnCounters{
+		Read:   newTestIOCounters(),
+		Write:  newTestIOCounters(),
+		Opened: &Counter{},
+		Active: &Counter{},
+	}
+	l = NewListener(l, server)
+	defer l.Close()
+
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, io.LimitReader(conn, 4))
+	}()
+
+	client := ConnCounters{Read: newTestIOCounters(), Write: newTestIOCounters()}
+	rawConn, err := net.Dial("tcp", l.Addr().String())
+	require.Nil(t, err)
+	conn := NewConn(rawConn, client)
+
+	_, ok := conn.(io.ReaderFrom)
+	assert.True(t, ok)
+
+	_, err = conn.Write([]byte("ping"))
+	require.Nil(t, err)
+	data, err := ioutil.ReadAll(conn)
+	require.Nil(t, err)
+	assert.Equal(t, "ping", string(data))
+	require.Nil(t, conn.Close())
+	<-doneCh
+
+	assert.Equal(t, int64(4), client.Write.Bytes.Get())
+	assert.Equal(t, int64(4), client.Read.Bytes.Get())
+	assert.Equal(t, int64(4), server.Read.Bytes.Get())
+	assert.Equal(t, int64(4), server.Write.Bytes.Get())
+	assert.Equal(t, int64(1), server.Opened.Get())
+	assert.Equal(t, int64(0), server.Active.Get())
+}
+
+type readWriteCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (rwc *readWriteCloser) Close() error {
+	rwc.closed = true
+	return nil
+}
+
+func TestIOReadWriteCloser(t *testing.T) {
+	read, write := newTestIOCounters(), newTestIOCounters()
+	raw := &readWriteCloser{}
+	rwc := NewReadWriteCloser(raw, read, write)
+
+	_, ok := rwc.(io.WriterTo)
+	assert.True(t, ok)
+	_, ok = rwc.(io.ReaderFrom)
+	assert.True(t, ok)
+
+	_, err := rwc.Write([]byte("hello"))
+	require.Nil(t, err)
+	data, err := ioutil.ReadAll(rwc)
+	require.Nil(t, err)
+	assert.Equal(t, "hello", string(data))
+	require.Nil(t, rwc.Close())
+
+	assert.True(t, raw.closed)
+	assert.Equal(t, int64(5), read.Bytes.Get())
+	assert.Equal(t, int64(5), write.Bytes.Get())
+}
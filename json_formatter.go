@@ -2,15 +2,61 @@ package gometer
 
 import (
 	"bytes"
-	"fmt"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
 )
 
+// JSONFormatterParams represents params of a JSON formatter.
+//
+// Indent enables pretty-printing with the given indentation, e.g. "  ".
+// Timestamp and Metadata wrap metrics into an envelope:
+// {"ts":<unix milliseconds>,"meta":{<metadata>},"metrics":{<metrics>}}.
+// Now is used to get the timestamp, time.Now is used if it's nil.
+type JSONFormatterParams struct {
+	Indent    string
+	Timestamp bool
+	Metadata  map[string]string
+	Now       func() time.Time
+}
+
+// NewJSONFormatter returns a formatter that writes metrics as a JSON object,
+// e.g. {"http.errors":1,"load":0.5}.
+//
+// Non-finite values of gauges are written as null.
+func NewJSONFormatter(params JSONFormatterParams) Formatter {
+	if params.Now == nil {
+		params.Now = time.Now
+	}
+	return &jsonFormatter{params: params}
+}
+
 type jsonFormatter struct {
+	params JSONFormatterParams
 }
 
 func (f *jsonFormatter) Format(counters SortedCounters) []byte {
 	var buf bytes.Buffer
 
+	envelope := f.params.Timestamp || len(f.params.Metadata) > 0
+	if envelope {
+		buf.WriteRune('{')
+		if f.params.Timestamp {
+			buf.WriteString(`"ts":`)
+			buf.WriteString(strconv.FormatInt(f.params.Now().UnixNano()/int64(time.Millisecond), 10))
+			buf.WriteRune(',')
+		}
+		if len(f.params.Metadata) > 0 {
+			buf.WriteString(`"meta":`)
+			writeJSONMap(&buf, f.params.Metadata)
+			buf.WriteRune(',')
+		}
+		buf.WriteString(`"metrics":`)
+	}
+
 	buf.WriteRune('{')
 
 	first := true
@@ -20,12 +66,93 @@ func (f *jsonFormatter) Format(counters SortedCounters) []byte {
 		} else {
 			buf.WriteRune(',')
 		}
-		fmt.Fprintf(&buf, `"%s":%d`, c.Name, c.Counter.Get())
+		writeJSONString(&buf, c.Name)
+		buf.WriteRune(':')
+		buf.WriteString(formatJSONValue(c))
 	}
 
 	buf.WriteRune('}')
 
+	if envelope {
+		buf.WriteRune('}')
+	}
+
+	if f.params.Indent != "" {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, buf.Bytes(), "", f.params.Indent); err == nil {
+			indented.WriteRune('\n')
+			return indented.Bytes()
+		}
+	}
 	return buf.Bytes()
 }
 
 var _ Formatter = (*jsonFormatter)(nil)
+
+// formatJSONValue returns a JSON representation of an entry value.
+func formatJSONValue(e CounterEntry) string {
+	if e.Kind == KindGauge && (math.IsNaN(e.Gauge) || math.IsInf(e.Gauge, 0)) {
+		return "null"
+	}
+	return formatValue(e)
+}
+
+func writeJSONMap(buf *bytes.Buffer, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, k)
+		buf.WriteByte(':')
+		writeJSONString(buf, m[k])
+	}
+	buf.WriteByte('}')
+}
+
+// writeJSONString writes s as a quoted JSON string.
+// Invalid UTF-8 sequences are replaced with U+FFFD.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case c == '\n':
+				buf.WriteString(`\n`)
+			case c == '\r':
+				buf.WriteString(`\r`)
+			case c == '\t':
+				buf.WriteString(`\t`)
+			case c < 0x20:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[c>>4])
+				buf.WriteByte(hex[c&0xf])
+			default:
+				buf.WriteByte(c)
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteString(`�`)
+		} else {
+			buf.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,43 @@
+//go:build go1.18
+// +build go1.18
+
+package gometer
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func FuzzJSONFormatter(f *testing.F) {
+	f.Add("http.errors", int64(1), 0.5, "host", "a")
+	f.Add(`quote"\`, int64(-1), math.NaN(), "\x00", "\xff")
+	f.Add("привет\n", int64(math.MaxInt64), math.Inf(-1), "", "")
+
+	f.Fuzz(func(t *testing.T, name string, counter int64, gauge float64, key, value string) {
+		c := &Counter{}
+		c.Set(counter)
+		counters := SortedCounters{
+			{Name: name, Counter: c, Kind: KindCounter},
+			newGaugeEntry(name+".gauge", gauge),
+		}
+
+		for _, params := range []JSONFormatterParams{
+			{},
+			{Indent: "\t", Timestamp: true, Metadata: map[string]string{key: value}},
+		} {
+			data := NewJSONFormatter(params).Format(counters)
+			if !json.Valid(data) {
+				t.Fatalf("invalid json: %q", data)
+			}
+
+			s, err := NewJSONParser().Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(s.Counters) != 2 || s.Counters[0].Counter.Get() != counter {
+				t.Fatalf("unexpected metrics %+v of %q", s.Counters, data)
+			}
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package gometer
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogfmtParams represents params of a logfmt formatter.
+//
+// SingleLine writes all metrics as keys of one line: ts=... http.errors=1 load=0.5,
+// otherwise every metric is written on its own line: ts=... name=http.errors value=1.
+// Now is used to get the timestamp, time.Now is used if it's nil.
+// NoTimestamp disables the ts key.
+type LogfmtParams struct {
+	SingleLine  bool
+	Now         func() time.Time
+	NoTimestamp bool
+}
+
+// NewLogfmtFormatter returns a formatter of logfmt lines.
+//
+// Timestamps are written in RFC 3339 format. Characters that aren't allowed
+// in keys are replaced by '_', values are quoted if necessary.
+func NewLogfmtFormatter(params LogfmtParams) Formatter {
+	if params.Now == nil {
+		params.Now = time.Now
+	}
+	return &logfmtFormatter{params: params}
+}
+
+type logfmtFormatter struct {
+	params LogfmtParams
+}
+
+var _ Formatter = (*logfmtFormatter)(nil)
+
+func (f *logfmtFormatter) Format(counters SortedCounters) []byte {
+	var buf bytes.Buffer
+
+	ts := f.params.Now().Format(time.RFC3339Nano)
+	if f.params.SingleLine {
+		if len(counters) == 0 {
+			return nil
+		}
+		if !f.params.NoTimestamp {
+			buf.WriteString("ts=")
+			buf.WriteString(ts)
+		}
+		for _, c := range counters {
+			if buf.Len() > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(logfmtKey(c.Name))
+			buf.WriteByte('=')
+			buf.WriteString(formatValue(c))
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes()
+	}
+
+	for _, c := range counters {
+		if !f.params.NoTimestamp {
+			buf.WriteString("ts=")
+			buf.WriteString(ts)
+			buf.WriteByte(' ')
+		}
+		buf.WriteString("name=")
+		buf.WriteString(logfmtValue(c.Name))
+		buf.WriteString(" value=")
+		buf.WriteString(formatValue(c))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// logfmtKey replaces characters that aren't allowed in logfmt keys by '_'.
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue quotes a value if it contains spaces, quotes, '=' or control characters.
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	if strings.IndexFunc(value, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f
+	}) >= 0 {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -1,8 +1,10 @@
 package gometer
 
 import (
+	"bufio"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"sort"
 	"sync"
@@ -17,7 +19,12 @@ type Metrics interface {
 	SetFormatter(Formatter)
 	Formatter() Formatter
 	Get(string) *Counter
+	GetMonotonic(string) *MonotonicCounter
+	GaugeFunc(string, func() float64)
+	CounterFunc(string, func() int64)
+	MonotonicFunc(string, func() int64)
 	GetJSON(func(string) bool) []byte
+	Snapshot() Snapshot
 	WithPrefix(string, ...interface{}) *PrefixMetrics
 	Write() error
 	StartFileWriter(FileWriterParams) Stopper
@@ -25,15 +32,18 @@ type Metrics interface {
 
 // DefaultMetrics is a default implementation of Metrics.
 type DefaultMetrics struct {
-	wg       sync.WaitGroup
-	stopOnce sync.Once
-	cancelCh chan struct{}
-
 	mu         sync.Mutex
 	out        io.Writer
 	counters   map[string]*Counter
+	monotonic  map[string]*MonotonicCounter
+	funcs      map[string]*funcMetric
+	derived    map[string]exprNode
+	restored   map[string]struct{}
 	formatter  Formatter
 	rootPrefix string
+
+	funcTimeout      time.Duration
+	funcErrorHandler func(err error)
 }
 
 var _ Metrics = (*DefaultMetrics)(nil)
@@ -42,14 +52,22 @@ var _ Metrics = (*PrefixMetrics)(nil)
 // FileWriterParams represents a params for asynchronous file writing operation.
 //
 // FilePath represents a file path.
-// UpdateInterval determines how often metrics data will be written to a file.
+// UpdateInterval determines how often metrics data will be written to a file,
+// it must be positive.
 // NoFlushOnStop disables metrics flushing when the metrics writer finishes.
 // ErrorHandler allows to handle errors from the goroutine that writes metrics.
+// Delta makes the writer write increments of counters since its previous write
+// instead of absolute values, gauges are written as is.
+// Append makes the writer append metrics to a file instead of rewriting it,
+// e.g. to collect a time series with NewCSVFormatter(). The file is rewritten
+// only when the formatter extends the header of the file.
 type FileWriterParams struct {
 	FilePath       string
 	UpdateInterval time.Duration
 	NoFlushOnStop  bool
 	ErrorHandler   func(err error)
+	Delta          bool
+	Append         bool
 }
 
 // Default is a standard metrics object.
@@ -58,10 +76,14 @@ var Default = New()
 // New creates new empty collection of metrics.
 func New() *DefaultMetrics {
 	m := &DefaultMetrics{
-		out:       os.Stderr,
-		counters:  make(map[string]*Counter),
-		formatter: NewFormatter("\n"),
-		cancelCh:  make(chan struct{}),
+		out:         os.Stderr,
+		counters:    make(map[string]*Counter),
+		monotonic:   make(map[string]*MonotonicCounter),
+		funcs:       make(map[string]*funcMetric),
+		derived:     make(map[string]exprNode),
+		restored:    make(map[string]struct{}),
+		formatter:   NewFormatter("\n"),
+		funcTimeout: defaultFuncTimeout,
 	}
 	return m
 }
@@ -87,6 +109,22 @@ func (m *DefaultMetrics) SetRootPrefix(prefix string) {
 	m.rootPrefix = prefix
 }
 
+// SetFuncTimeout sets how long a callback registered by GaugeFunc or CounterFunc
+// may run before its last known value is used instead.
+func (m *DefaultMetrics) SetFuncTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.funcTimeout = timeout
+}
+
+// SetFuncErrorHandler sets a handler for callbacks registered by GaugeFunc
+// or CounterFunc that panic or time out.
+func (m *DefaultMetrics) SetFuncErrorHandler(h func(err error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.funcErrorHandler = h
+}
+
 // Formatter returns a metrics formatter.
 func (m *DefaultMetrics) Formatter() Formatter {
 	m.mu.Lock()
@@ -95,47 +133,181 @@ func (m *DefaultMetrics) Formatter() Formatter {
 }
 
 // Get returns counter by name. If counter doesn't exist it will be created.
+//
+// Get panics if the name is already used by a metric of another kind.
 func (m *DefaultMetrics) Get(counterName string) *Counter {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	if c, ok := m.counters[counterName]; ok {
+		// the counter is claimed as a plain one, so it can't be adopted anymore.
+		delete(m.restored, counterName)
 		return c
 	}
+	m.checkNameLocked(counterName)
 
 	c := &Counter{}
 	m.counters[counterName] = c
 	return c
 }
 
+// GetMonotonic returns monotonic counter by name. If counter doesn't exist it will be created.
+//
+// GetMonotonic panics if the name is already used by a metric of another kind.
+func (m *DefaultMetrics) GetMonotonic(counterName string) *MonotonicCounter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.monotonic[counterName]; ok {
+		return c
+	}
+	restored, isRestored := m.adoptRestoredLocked(counterName)
+	m.checkNameLocked(counterName)
+
+	// a restored counter started before the process, so its creation time is unknown.
+	c := &MonotonicCounter{}
+	if isRestored && restored >= 0 {
+		c.counter.Set(restored)
+	} else {
+		c.created = time.Now()
+	}
+	m.monotonic[counterName] = c
+	return c
+}
+
+// GaugeFunc registers a gauge whose value is computed by f every time metrics
+// are written or a snapshot is taken. Registering the same name again replaces f.
+//
+// f is called without holding any locks of metrics, so it may use metrics itself.
+// If f panics or doesn't return in time (see SetFuncTimeout), its last value is used.
+//
+// GaugeFunc panics if the name is already used by a metric of another kind.
+func (m *DefaultMetrics) GaugeFunc(name string, f func() float64) {
+	m.registerFunc(name, newGaugeFunc(f))
+}
+
+// CounterFunc registers a counter whose value is computed by f every time metrics
+// are written or a snapshot is taken. For more details see DefaultMetrics.GaugeFunc().
+func (m *DefaultMetrics) CounterFunc(name string, f func() int64) {
+	m.registerFunc(name, newCounterFunc(f))
+}
+
+// MonotonicFunc registers a monotonic counter whose value is computed by f, e.g. a total
+// read from the runtime or the OS. A decrease of the value means a reset of the source.
+// For more details see DefaultMetrics.GaugeFunc().
+func (m *DefaultMetrics) MonotonicFunc(name string, f func() int64) {
+	m.registerFunc(name, newMonotonicFunc(f))
+}
+
+func (m *DefaultMetrics) registerFunc(name string, f *funcMetric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.funcs[name]; !ok {
+		m.adoptRestoredLocked(name)
+		m.checkNameLocked(name)
+	}
+	m.funcs[name] = f
+}
+
+// adoptRestoredLocked removes a counter created by Load and not claimed by Get yet,
+// so the name can be registered with another kind. It returns the restored value.
+func (m *DefaultMetrics) adoptRestoredLocked(name string) (int64, bool) {
+	if _, ok := m.restored[name]; !ok {
+		return 0, false
+	}
+	val := m.counters[name].Get()
+	delete(m.restored, name)
+	delete(m.counters, name)
+	return val, true
+}
+
+func (m *DefaultMetrics) checkNameLocked(name string) {
+	if err := m.nameConflictLocked(name); err != nil {
+		panic(err.Error())
+	}
+}
+
+// nameConflictLocked returns an error if the name is already used by a metric.
+func (m *DefaultMetrics) nameConflictLocked(name string) error {
+	_, isCounter := m.counters[name]
+	_, isMonotonic := m.monotonic[name]
+	_, isFunc := m.funcs[name]
+	_, isDerived := m.derived[name]
+	if isCounter || isMonotonic || isFunc || isDerived {
+		return fmt.Errorf("gometer: metric %q is already registered with another kind", name)
+	}
+	return nil
+}
+
 // GetJSON filters counters by given predicate and returns them as a json marshaled map.
 func (m *DefaultMetrics) GetJSON(predicate func(string) bool) []byte {
+	formatter := jsonFormatter{}
+	return formatter.Format(m.snapshot(predicate).Counters)
+}
+
+// Snapshot returns current values of all metrics.
+func (m *DefaultMetrics) Snapshot() Snapshot {
+	return m.snapshot(nil)
+}
+
+// snapshot collects metrics accepted by predicate.
+// If predicate is nil all metrics will be collected.
+func (m *DefaultMetrics) snapshot(predicate func(string) bool) Snapshot {
+	m.mu.Lock()
+	// derived metrics may use any metric, so all of them are collected.
+	collect := predicate
+	if len(m.derived) > 0 {
+		collect = nil
+	}
+	funcs := make(map[string]*funcMetric)
+	for k, v := range m.funcs {
+		if collect == nil || collect(k) {
+			funcs[k] = v
+		}
+	}
+	timeout, errorHandler := m.funcTimeout, m.funcErrorHandler
+	m.mu.Unlock()
+
+	// callbacks are evaluated without lock, so they can use metrics.
+	evalFuncMetrics(funcs, timeout, errorHandler)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	result := make(map[string]*Counter)
+	s := make(SortedCounters, 0, len(m.counters)+len(m.monotonic)+len(funcs)+len(m.derived))
 	for k, v := range m.counters {
-		if predicate(k) {
-			result[k] = v
+		if collect == nil || collect(k) {
+			s = append(s, CounterEntry{Name: k, Counter: copyCounter(v), Kind: KindCounter})
 		}
 	}
+	for k, v := range m.monotonic {
+		if collect == nil || collect(k) {
+			e := CounterEntry{Name: k, Counter: copyCounter(&v.counter), Kind: KindMonotonic, Created: v.created}
+			if exemplar, ok := v.Exemplar(); ok {
+				e.Exemplar = &exemplar
+			}
+			s = append(s, e)
+		}
+	}
+	for k, v := range funcs {
+		s = append(s, v.entry(k))
+	}
+	s = m.deriveLocked(s, predicate)
 
-	formatter := jsonFormatter{}
-	return formatter.Format(m.makeSortedCounters(result))
-}
-
-func (m *DefaultMetrics) makeSortedCounters(counters map[string]*Counter) SortedCounters {
-	s := make(SortedCounters, 0, len(counters))
-	for k, v := range counters {
-		s = append(s, struct {
-			Name    string
-			Counter *Counter
-		}{Name: m.rootPrefix + k, Counter: v})
+	for i := range s {
+		s[i].Name = m.rootPrefix + s[i].Name
 	}
 	sort.Slice(s, func(i, j int) bool {
 		return s[i].Name < s[j].Name
 	})
-	return s
+	return Snapshot{Time: time.Now(), Counters: s}
+}
+
+func copyCounter(c *Counter) *Counter {
+	cp := &Counter{}
+	cp.Set(c.Get())
+	return cp
 }
 
 // Write writes all existing metrics to output destination.
@@ -144,10 +316,12 @@ func (m *DefaultMetrics) makeSortedCounters(counters map[string]*Counter) Sorted
 // It appends existing metrics to existing file's data.
 // if you want to write metrics to clear file use StartFileWriter() method.
 func (m *DefaultMetrics) Write() error {
+	s := m.snapshot(nil)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	data := m.formatter.Format(m.makeSortedCounters(m.counters))
+	data := m.formatter.Format(s.Counters)
 
 	if _, err := m.out.Write(data); err != nil {
 		return err
@@ -157,24 +331,17 @@ func (m *DefaultMetrics) Write() error {
 }
 
 // StartFileWriter starts a goroutine that periodically writes metrics to a file.
+//
+// Each call starts an independent writer, stopping one of them doesn't affect others.
 func (m *DefaultMetrics) StartFileWriter(params FileWriterParams) Stopper {
-	m.wg.Add(1)
-
-	go func() {
-		defer m.wg.Done()
-		m.run(params)
-	}()
-
-	return &stopperFunc{stop: func() {
-		m.stopOnce.Do(func() {
-			close(m.cancelCh)
+	var deltas *deltaTracker
+	if params.Delta {
+		deltas = &deltaTracker{}
+	}
 
-			m.wg.Wait()
-			if !params.NoFlushOnStop {
-				m.handleFileWrite(params)
-			}
-		})
-	}}
+	return startPeriodic(params.UpdateInterval, !params.NoFlushOnStop, func() {
+		m.handleFileWrite(params, deltas)
+	})
 }
 
 // WithPrefix creates new PrefixMetrics that uses original Metrics with specified prefix.
@@ -207,6 +374,30 @@ func Get(counterName string) *Counter {
 	return Default.Get(counterName)
 }
 
+// GetMonotonic returns monotonic counter by name. If counter doesn't exist it will be created.
+// For more details see DefaultMetrics.GetMonotonic().
+func GetMonotonic(counterName string) *MonotonicCounter {
+	return Default.GetMonotonic(counterName)
+}
+
+// GaugeFunc registers a gauge computed by f for standard metrics.
+// For more details see DefaultMetrics.GaugeFunc().
+func GaugeFunc(name string, f func() float64) {
+	Default.GaugeFunc(name, f)
+}
+
+// CounterFunc registers a counter computed by f for standard metrics.
+// For more details see DefaultMetrics.CounterFunc().
+func CounterFunc(name string, f func() int64) {
+	Default.CounterFunc(name, f)
+}
+
+// MonotonicFunc registers a monotonic counter computed by f for standard metrics.
+// For more details see DefaultMetrics.MonotonicFunc().
+func MonotonicFunc(name string, f func() int64) {
+	Default.MonotonicFunc(name, f)
+}
+
 // GetJSON filters counters by given predicate and returns them as a json marshaled map.
 func GetJSON(predicate func(string) bool) []byte {
 	return Default.GetJSON(predicate)
@@ -230,22 +421,18 @@ func WithPrefix(prefix string, v ...interface{}) *PrefixMetrics {
 	return Default.WithPrefix(prefix, v...)
 }
 
-func (m *DefaultMetrics) run(params FileWriterParams) {
-	ticker := time.NewTicker(params.UpdateInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			m.handleFileWrite(params)
-		case <-m.cancelCh:
-			return
-		}
+func (m *DefaultMetrics) handleFileWrite(params FileWriterParams, deltas *deltaTracker) {
+	counters := m.snapshot(nil).Counters
+	if deltas != nil {
+		counters = deltas.next(counters)
 	}
-}
 
-func (m *DefaultMetrics) handleFileWrite(params FileWriterParams) {
-	err := m.createAndWriteFile(params.FilePath)
+	var err error
+	if params.Append {
+		err = m.appendToFile(params.FilePath, counters)
+	} else {
+		err = m.createAndWriteFile(params.FilePath, m.format(counters))
+	}
 	if err != nil {
 		if params.ErrorHandler != nil {
 			params.ErrorHandler(err)
@@ -255,7 +442,68 @@ func (m *DefaultMetrics) handleFileWrite(params FileWriterParams) {
 	}
 }
 
-func (m *DefaultMetrics) createAndWriteFile(path string) error {
+// headerFormatter is implemented by formatters that write a header before the first
+// output, so they can continue a file that already has it instead of writing another one,
+// and rewrite the header of the file if it's extended.
+type headerFormatter interface {
+	resumeHeader(firstLine func() []byte)
+	headerExtended() bool
+	rewriteHeader(data []byte) ([]byte, error)
+}
+
+// format formats counters with the formatter of metrics.
+func (m *DefaultMetrics) format(counters SortedCounters) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.formatter.Format(counters)
+}
+
+// appendToFile appends formatted counters to a file. If the formatter extends
+// the header of the file, the file is rewritten with the new header.
+func (m *DefaultMetrics) appendToFile(path string, counters SortedCounters) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.formatter.(headerFormatter)
+	if !ok {
+		return appendFile(path, m.formatter.Format(counters))
+	}
+
+	h.resumeHeader(func() []byte {
+		return readFirstLine(path)
+	})
+	data := m.formatter.Format(counters)
+	if !h.headerExtended() {
+		return appendFile(path, data)
+	}
+
+	old, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	rewritten, err := h.rewriteHeader(old)
+	if err != nil {
+		return err
+	}
+	return m.createAndWriteFile(path, append(rewritten, data...))
+}
+
+// readFirstLine returns the first line of a file or nil if it can't be read.
+func readFirstLine(path string) []byte {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil
+	}
+	return line
+}
+
+func (m *DefaultMetrics) createAndWriteFile(path string, data []byte) error {
 	// create an empty temporary file.
 	file, err := safefile.Create(path, 0644)
 	if err != nil {
@@ -263,8 +511,7 @@ func (m *DefaultMetrics) createAndWriteFile(path string) error {
 	}
 	defer file.Close()
 
-	m.SetOutput(file)
-	if err = m.Write(); err != nil {
+	if _, err = file.Write(data); err != nil {
 		return err
 	}
 
@@ -272,3 +519,16 @@ func (m *DefaultMetrics) createAndWriteFile(path string) error {
 	// it's necessary for atomic file rewriting.
 	return file.Commit()
 }
+
+func appendFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -1,6 +1,8 @@
 package gometer
 
 import (
+	"bytes"
+	"encoding/csv"
 	"io/ioutil"
 	"os"
 	"strconv"
@@ -97,6 +99,147 @@ func TestMetricsStartFileWriter(t *testing.T) {
 	})
 }
 
+func TestMetricsStartFileWriterDelta(t *testing.T) {
+	t.Parallel()
+
+	file := newTempFile(t)
+	require.Nil(t, file.Close())
+	defer os.Remove(file.Name())
+
+	metrics := New()
+	metrics.Get("add_num").Add(10)
+	metrics.GaugeFunc("load", func() float64 { return 0.5 })
+
+	metrics.StartFileWriter(FileWriterParams{
+		FilePath:       file.Name(),
+		UpdateInterval: time.Hour,
+		Delta:          true,
+	}).Stop()
+
+	// the first write contains increments since zero.
+	data, err := ioutil.ReadFile(file.Name())
+	require.Nil(t, err)
+	assert.Equal(t, "add_num = 10\nload = 0.5\n", string(data))
+}
+
+func TestMetricsFileWriterDeltaFlushes(t *testing.T) {
+	t.Parallel()
+
+	file := newTempFile(t)
+	require.Nil(t, file.Close())
+	defer os.Remove(file.Name())
+
+	metrics := New()
+	requests := metrics.GetMonotonic("requests")
+	queue := metrics.Get("queue")
+	load := 0.5
+	metrics.GaugeFunc("load", func() float64 { return load })
+
+	// flushes of one writer share the tracker of previous values.
+	params := FileWriterParams{FilePath: file.Name(), Delta: true}
+	deltas := &deltaTracker{}
+	flush := func() string {
+		metrics.handleFileWrite(params, deltas)
+		data, err := ioutil.ReadFile(file.Name())
+		require.Nil(t, err)
+		return string(data)
+	}
+
+	require.Nil(t, requests.Add(10))
+	queue.Set(5)
+	assert.Equal(t, "load = 0.5\nqueue = 5\nrequests = 10\n", flush())
+
+	require.Nil(t, requests.Add(3))
+	queue.Set(2)
+	load = 0.75
+	metrics.Get("added").Set(7)
+	assert.Equal(t, "added = 7\nload = 0.75\nqueue = -3\nrequests = 3\n", flush())
+
+	// nothing changed.
+	assert.Equal(t, "added = 0\nload = 0.75\nqueue = 0\nrequests = 0\n", flush())
+}
+
+func TestMetricsStartFileWriterAppend(t *testing.T) {
+	t.Parallel()
+
+	file := newTempFile(t)
+	require.Nil(t, file.Close())
+	defer os.Remove(file.Name())
+
+	metrics := New()
+	metrics.SetFormatter(NewCSVFormatter(CSVParams{
+		Mode: CSVWide,
+		Now:  func() time.Time { return time.Unix(0, 0).UTC() },
+	}))
+	metrics.Get("add_num").Add(1)
+
+	params := FileWriterParams{
+		FilePath:       file.Name(),
+		UpdateInterval: time.Hour,
+		Append:         true,
+	}
+	metrics.StartFileWriter(params).Stop()
+	metrics.Get("add_num").Add(1)
+	metrics.StartFileWriter(params).Stop()
+
+	data, err := ioutil.ReadFile(file.Name())
+	require.Nil(t, err)
+	assert.Equal(t, "timestamp,add_num\n1970-01-01T00:00:00Z,1\n1970-01-01T00:00:00Z,2\n", string(data))
+
+	// a restarted process continues the file with its header and columns,
+	// new metrics extend the header and earlier rows are padded.
+	metrics = New()
+	metrics.SetFormatter(NewCSVFormatter(CSVParams{
+		Mode: CSVWide,
+		Now:  func() time.Time { return time.Unix(0, 0).UTC() },
+	}))
+	metrics.Get("new_num").Add(5)
+	metrics.Get("add_num").Add(3)
+	metrics.StartFileWriter(params).Stop()
+
+	data, err = ioutil.ReadFile(file.Name())
+	require.Nil(t, err)
+	assert.Equal(t, "timestamp,add_num,new_num\n1970-01-01T00:00:00Z,1,\n1970-01-01T00:00:00Z,2,\n1970-01-01T00:00:00Z,3,5\n", string(data))
+
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.Nil(t, err)
+	assert.Len(t, rows, 4)
+}
+
+func TestMetricsStartFileWriterIndependent(t *testing.T) {
+	t.Parallel()
+
+	file1, file2 := newTempFile(t), newTempFile(t)
+	require.Nil(t, file1.Close())
+	require.Nil(t, file2.Close())
+	defer os.Remove(file1.Name())
+	defer os.Remove(file2.Name())
+
+	metrics := New()
+	metrics.Get("add_num").Add(1)
+
+	metrics.StartFileWriter(FileWriterParams{
+		FilePath:       file1.Name(),
+		UpdateInterval: time.Hour,
+		NoFlushOnStop:  true,
+	}).Stop()
+
+	defer metrics.StartFileWriter(FileWriterParams{
+		FilePath:       file2.Name(),
+		UpdateInterval: time.Millisecond * 10,
+	}).Stop()
+
+	checkFileWriter(t, file2.Name(), "\n", map[string]int64{
+		"add_num": int64(1),
+	})
+}
+
+func TestMetricsStartFileWriterNoInterval(t *testing.T) {
+	assert.Panics(t, func() {
+		New().StartFileWriter(FileWriterParams{FilePath: "unused"})
+	})
+}
+
 func TestMetricsStartFileWriterError(t *testing.T) {
 	t.Run("handle error", func(t *testing.T) {
 		t.Parallel()
@@ -306,6 +449,31 @@ func TestMetricsSetRootPrefix(t *testing.T) {
 	})
 }
 
+func TestMetricsGetMonotonic(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	c := metrics.GetMonotonic("requests")
+	require.Nil(t, c.Add(3))
+	assert.True(t, c == metrics.GetMonotonic("requests"))
+
+	metrics.Get("errors").Set(-1)
+
+	b := metrics.GetJSON(func(string) bool { return true })
+	assert.JSONEq(t, `{"errors": -1, "requests": 3}`, string(b))
+}
+
+func TestMetricsKindConflict(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	metrics.Get("counter")
+	metrics.GetMonotonic("monotonic")
+
+	assert.Panics(t, func() { metrics.GetMonotonic("counter") })
+	assert.Panics(t, func() { metrics.Get("monotonic") })
+}
+
 func newTempFile(t *testing.T) *os.File {
 	file, err := ioutil.TempFile("", "gometer")
 	require.Nil(t, err)
@@ -0,0 +1,106 @@
+package gometer
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxExemplarLabelsLength is a maximum length of exemplar labels allowed by OpenMetrics.
+const maxExemplarLabelsLength = 128
+
+// Exemplar represents an observation of a metric linked to external data, e.g. a trace.
+type Exemplar struct {
+	Labels map[string]string
+	Value  float64
+	Time   time.Time
+}
+
+// MonotonicCounter represents a counter that can only grow.
+//
+// Unlike Counter it has no Set method and rejects negative values,
+// so consumers can safely compute deltas between two readings and
+// treat any decrease as a counter reset.
+type MonotonicCounter struct {
+	counter    Counter
+	violations Counter
+	created    time.Time
+
+	mu       sync.Mutex
+	exemplar *Exemplar
+}
+
+// Add adds the corresponding value to a counter.
+//
+// Negative value is rejected with ErrNegativeDelta, the counter remains
+// unchanged and the violation is recorded.
+func (c *MonotonicCounter) Add(val int64) error {
+	if val < 0 {
+		c.violations.Add(1)
+		return ErrNegativeDelta
+	}
+	c.counter.Add(val)
+	return nil
+}
+
+// AddWithExemplar adds the corresponding value to a counter and attaches
+// the observation as the latest exemplar of the counter, e.g. with
+// map[string]string{"trace_id": traceID}.
+//
+// Labels longer than 128 characters in total are rejected with ErrExemplarTooLong,
+// the value is added anyway. For negative values see MonotonicCounter.Add().
+func (c *MonotonicCounter) AddWithExemplar(val int64, labels map[string]string) error {
+	if err := c.Add(val); err != nil {
+		return err
+	}
+
+	length := 0
+	for k, v := range labels {
+		length += utf8.RuneCountInString(k) + utf8.RuneCountInString(v)
+	}
+	if length > maxExemplarLabelsLength {
+		return ErrExemplarTooLong
+	}
+
+	e := &Exemplar{Labels: make(map[string]string, len(labels)), Value: float64(val), Time: time.Now()}
+	for k, v := range labels {
+		e.Labels[k] = v
+	}
+
+	c.mu.Lock()
+	c.exemplar = e
+	c.mu.Unlock()
+	return nil
+}
+
+// Inc increments a counter by one.
+func (c *MonotonicCounter) Inc() {
+	c.counter.Add(1)
+}
+
+// Get returns the corresponding value for a counter.
+func (c *MonotonicCounter) Get() int64 {
+	return c.counter.Get()
+}
+
+// Violations returns how many times a negative value was rejected by Add.
+func (c *MonotonicCounter) Violations() int64 {
+	return c.violations.Get()
+}
+
+// Exemplar returns the latest exemplar of a counter if any.
+func (c *MonotonicCounter) Exemplar() (Exemplar, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.exemplar == nil {
+		return Exemplar{}, false
+	}
+	return *c.exemplar, true
+}
+
+// Created returns the time a counter was registered, it's zero for counters
+// created outside of Metrics and for counters that adopted values restored by Load.
+func (c *MonotonicCounter) Created() time.Time {
+	return c.created
+}
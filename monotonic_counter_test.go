@@ -0,0 +1,49 @@
+package gometer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonotonicCounterAdd(t *testing.T) {
+	c := MonotonicCounter{}
+	require.Nil(t, c.Add(10))
+	c.Inc()
+	assert.Equal(t, int64(11), c.Get())
+	assert.Equal(t, int64(0), c.Violations())
+}
+
+func TestMonotonicCounterAddNegative(t *testing.T) {
+	c := MonotonicCounter{}
+	require.Nil(t, c.Add(5))
+
+	assert.Equal(t, ErrNegativeDelta, c.Add(-1))
+	assert.Equal(t, ErrNegativeDelta, c.Add(-10))
+	assert.Equal(t, int64(5), c.Get())
+	assert.Equal(t, int64(2), c.Violations())
+}
+
+func TestMonotonicCounterAddWithExemplar(t *testing.T) {
+	c := MonotonicCounter{}
+	_, ok := c.Exemplar()
+	assert.False(t, ok)
+
+	labels := map[string]string{"trace_id": "abc"}
+	require.Nil(t, c.AddWithExemplar(3, labels))
+	labels["trace_id"] = "changed"
+
+	e, ok := c.Exemplar()
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"trace_id": "abc"}, e.Labels)
+	assert.Equal(t, float64(3), e.Value)
+	assert.False(t, e.Time.IsZero())
+
+	assert.Equal(t, ErrNegativeDelta, c.AddWithExemplar(-1, labels))
+	assert.Equal(t, ErrExemplarTooLong, c.AddWithExemplar(2, map[string]string{"trace_id": strings.Repeat("a", 121)}))
+	assert.Equal(t, int64(5), c.Get())
+	e, _ = c.Exemplar()
+	assert.Equal(t, float64(3), e.Value)
+}
@@ -0,0 +1,138 @@
+package gometer
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	nestedValueKey = "_value"
+	nestedTotalKey = "_total"
+)
+
+// NestedJSONParams represents params of a nested JSON formatter.
+//
+// Separator splits names into keys of nested objects, "." is used if it's empty.
+// Subtotals adds the sum of all values of a subtree to every interior node.
+type NestedJSONParams struct {
+	Separator string
+	Subtotals bool
+}
+
+// NewNestedJSONFormatter returns a formatter that writes metrics as nested JSON objects,
+// e.g. "svc.db.reads" and "svc.db.errors" are written as {"svc":{"db":{"errors":1,"reads":2}}}.
+//
+// A value of a name that is also a prefix of other names is written with "_value" key
+// next to its children, a subtotal is written with "_total" key.
+func NewNestedJSONFormatter(params NestedJSONParams) Formatter {
+	if params.Separator == "" {
+		params.Separator = "."
+	}
+	return &nestedJSONFormatter{params: params}
+}
+
+type nestedJSONFormatter struct {
+	params NestedJSONParams
+}
+
+var _ Formatter = (*nestedJSONFormatter)(nil)
+
+// nestedNode is a node of a prefix tree of metric names.
+type nestedNode struct {
+	entry    *CounterEntry
+	children map[string]*nestedNode
+}
+
+// newNestedTree builds a prefix tree of names split by sep.
+func newNestedTree(counters SortedCounters, sep string) *nestedNode {
+	root := &nestedNode{children: make(map[string]*nestedNode)}
+	for i := range counters {
+		n := root
+		for _, key := range strings.Split(counters[i].Name, sep) {
+			child, ok := n.children[key]
+			if !ok {
+				child = &nestedNode{children: make(map[string]*nestedNode)}
+				n.children[key] = child
+			}
+			n = child
+		}
+		n.entry = &counters[i]
+	}
+	return root
+}
+
+func (f *nestedJSONFormatter) Format(counters SortedCounters) []byte {
+	var buf bytes.Buffer
+	f.writeChildren(&buf, newNestedTree(counters, f.params.Separator), false)
+	return buf.Bytes()
+}
+
+// writeChildren writes an interior node as a JSON object.
+func (f *nestedJSONFormatter) writeChildren(buf *bytes.Buffer, n *nestedNode, subtotal bool) {
+	keys := make([]string, 0, len(n.children))
+	for key := range n.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf.WriteByte('{')
+	if n.entry != nil {
+		writeJSONString(buf, nestedValueKey)
+		buf.WriteByte(':')
+		buf.WriteString(formatJSONValue(*n.entry))
+		buf.WriteByte(',')
+	}
+	if subtotal {
+		writeJSONString(buf, nestedTotalKey)
+		buf.WriteByte(':')
+		buf.WriteString(n.total())
+		buf.WriteByte(',')
+	}
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, key)
+		buf.WriteByte(':')
+
+		child := n.children[key]
+		if len(child.children) == 0 {
+			buf.WriteString(formatJSONValue(*child.entry))
+		} else {
+			f.writeChildren(buf, child, f.params.Subtotals)
+		}
+	}
+	buf.WriteByte('}')
+}
+
+// total returns the sum of all values of a subtree.
+// The sum is an integer unless the subtree contains gauges.
+func (n *nestedNode) total() string {
+	var (
+		ints     int64
+		floats   float64
+		hasGauge bool
+	)
+	var walk func(n *nestedNode)
+	walk = func(n *nestedNode) {
+		if n.entry != nil {
+			if n.entry.Kind == KindGauge {
+				floats += n.entry.Gauge
+				hasGauge = true
+			} else {
+				ints += n.entry.Counter.Get()
+			}
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(n)
+
+	if hasGauge {
+		return formatJSONValue(newGaugeEntry("", floats+float64(ints)))
+	}
+	return strconv.FormatInt(ints, 10)
+}
@@ -0,0 +1,147 @@
+package gometer
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenMetricsParams represents params of an OpenMetrics formatter.
+//
+// Units maps names of metrics to their units, e.g. "request.duration" to "seconds".
+// The unit is appended to a name of a metric family unless it's already its suffix.
+type OpenMetricsParams struct {
+	Units map[string]string
+}
+
+// NewOpenMetricsFormatter returns a formatter of the OpenMetrics 1.0 text format.
+//
+// Names are sanitized as by SanitizePrometheusName. Names of metric families that
+// collide, including names of their samples, e.g. a counter "a" with a gauge "a_total",
+// are made unique by suffixes "_2", "_3" and so on before units. Monotonic counters are
+// exposed as counters with the "_total" suffix, their created timestamps and
+// exemplars (see MonotonicCounter.AddWithExemplar), gauges are exposed as gauges
+// and other counters as unknown metrics.
+//
+// The output is terminated by "# EOF", so it's a complete exposition
+// that isn't intended to be appended to a file (see FileWriterParams.Append).
+func NewOpenMetricsFormatter(params OpenMetricsParams) Formatter {
+	return &openMetricsFormatter{params: params}
+}
+
+type openMetricsFormatter struct {
+	params OpenMetricsParams
+}
+
+var _ Formatter = (*openMetricsFormatter)(nil)
+
+func (f *openMetricsFormatter) Format(counters SortedCounters) []byte {
+	var buf bytes.Buffer
+
+	names, units := f.familyNames(counters)
+	for i, c := range counters {
+		name, unit := names[i], units[i]
+
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, openMetricsType(c.Kind))
+		if unit != "_" {
+			fmt.Fprintf(&buf, "# UNIT %s %s\n", name, unit)
+		}
+
+		if c.Kind != KindMonotonic {
+			fmt.Fprintf(&buf, "%s %s\n", name, formatValue(c))
+			continue
+		}
+
+		fmt.Fprintf(&buf, "%s_total %s", name, formatValue(c))
+		if c.Exemplar != nil {
+			buf.WriteString(" # ")
+			writeOpenMetricsLabels(&buf, c.Exemplar.Labels)
+			fmt.Fprintf(&buf, " %s %s",
+				strconv.FormatFloat(c.Exemplar.Value, 'g', -1, 64),
+				formatOpenMetricsTime(c.Exemplar.Time))
+		}
+		buf.WriteByte('\n')
+		if !c.Created.IsZero() {
+			fmt.Fprintf(&buf, "%s_created %s\n", name, formatOpenMetricsTime(c.Created))
+		}
+	}
+	buf.WriteString("# EOF\n")
+
+	return buf.Bytes()
+}
+
+// familyNames returns unique names of metric families of counters and their units.
+func (f *openMetricsFormatter) familyNames(counters SortedCounters) ([]string, []string) {
+	bases := make([]string, len(counters))
+	units := make([]string, len(counters))
+	for i, c := range counters {
+		bases[i] = SanitizePrometheusName(c.Name)
+		if c.Kind == KindMonotonic {
+			bases[i] = strings.TrimSuffix(bases[i], "_total")
+		}
+		units[i] = SanitizePrometheusName(f.params.Units[c.Name])
+		if units[i] != "_" && strings.HasSuffix(bases[i], "_"+units[i]) {
+			bases[i] = strings.TrimSuffix(bases[i], "_"+units[i])
+		}
+	}
+
+	family := func(i int, suffix string) string {
+		name := bases[i] + suffix
+		if units[i] != "_" {
+			name += "_" + units[i]
+		}
+		return name
+	}
+	suffixes := uniqueSuffixes(len(counters), func(i int, suffix string) []string {
+		name := family(i, suffix)
+		if counters[i].Kind == KindMonotonic {
+			return []string{name, name + "_total", name + "_created"}
+		}
+		return []string{name}
+	})
+
+	names := make([]string, len(counters))
+	for i := range counters {
+		names[i] = family(i, suffixes[i])
+	}
+	return names, units
+}
+
+func openMetricsType(k Kind) string {
+	switch k {
+	case KindMonotonic:
+		return "counter"
+	case KindGauge:
+		return "gauge"
+	default:
+		return "unknown"
+	}
+}
+
+// formatOpenMetricsTime formats t as Unix seconds with millisecond precision.
+func formatOpenMetricsTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano()/int64(time.Millisecond))/1e3, 'f', 3, 64)
+}
+
+func writeOpenMetricsLabels(buf *bytes.Buffer, labels map[string]string) {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(SanitizePrometheusName(k))
+		buf.WriteString(`="`)
+		buf.WriteString(prometheusLabelEscaper.Replace(labels[k]))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('}')
+}
@@ -0,0 +1,392 @@
+package gometer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OTLPEncoding determines an encoding of OTLP requests.
+type OTLPEncoding int
+
+const (
+	// OTLPProtobuf encodes requests as binary protobuf messages.
+	OTLPProtobuf OTLPEncoding = iota
+	// OTLPJSON encodes requests as JSON messages.
+	OTLPJSON
+)
+
+const (
+	defaultOTLPTimeout      = 10 * time.Second
+	defaultOTLPRetryBackoff = 100 * time.Millisecond
+
+	otlpInstrumentationScope = "github.com/dshil/gometer"
+)
+
+// OTLPExporterParams represents params of an exporter of metrics to an OTLP/HTTP endpoint.
+//
+// Endpoint is a full URL of a collector, e.g. "http://localhost:4318/v1/metrics".
+// Resource contains attributes of the exported resource, e.g. "service.name".
+// Headers are added to every request, e.g. for authentication.
+// UpdateInterval must be positive.
+// Timeout limits every attempt to export metrics, 10 seconds by default.
+// Retries is a number of additional attempts after failures that may be temporary:
+// network errors and 429, 502, 503, 504 statuses. RetryBackoff is a delay before
+// the first retry, 100 milliseconds by default, it's doubled for every next one.
+// A delay in the Retry-After header of 429 and 503 responses is used instead if it's set.
+// Waits for retries are interrupted by Stop, the export on Stop isn't retried.
+// Client is used to send requests, http.DefaultClient is used if it's nil.
+// ErrorHandler allows to handle failed exports, they're ignored if it's nil.
+// NoFlushOnStop disables export of metrics when the exporter is stopped.
+type OTLPExporterParams struct {
+	Endpoint       string
+	Encoding       OTLPEncoding
+	Resource       map[string]string
+	Headers        map[string]string
+	UpdateInterval time.Duration
+	Timeout        time.Duration
+	Retries        int
+	RetryBackoff   time.Duration
+	Client         *http.Client
+	ErrorHandler   func(err error)
+	NoFlushOnStop  bool
+}
+
+// StartOTLPExporter starts a goroutine that exports metrics of m to an OTLP/HTTP endpoint
+// every params.UpdateInterval.
+//
+// Monotonic counters are exported as monotonic sums with cumulative temporality,
+// other counters and gauges are exported as gauges. Metrics have no histograms,
+// so none are exported.
+func StartOTLPExporter(m Metrics, params OTLPExporterParams) Stopper {
+	e := newOTLPExporter(params)
+	periodic := startPeriodic(params.UpdateInterval, !params.NoFlushOnStop, func() {
+		if err := e.export(m.Snapshot()); err != nil && params.ErrorHandler != nil {
+			params.ErrorHandler(err)
+		}
+	})
+
+	var stopOnce sync.Once
+	return &stopperFunc{stop: func() {
+		stopOnce.Do(func() { close(e.stopCh) })
+		periodic.Stop()
+	}}
+}
+
+type otlpExporter struct {
+	params OTLPExporterParams
+	// start is used as a start time of sums without created time.
+	start time.Time
+	// stopCh is closed when the exporter is stopped to interrupt waits for retries.
+	stopCh chan struct{}
+}
+
+func newOTLPExporter(params OTLPExporterParams) *otlpExporter {
+	if params.Timeout <= 0 {
+		params.Timeout = defaultOTLPTimeout
+	}
+	if params.RetryBackoff <= 0 {
+		params.RetryBackoff = defaultOTLPRetryBackoff
+	}
+	if params.Client == nil {
+		params.Client = http.DefaultClient
+	}
+
+	return &otlpExporter{params: params, start: time.Now(), stopCh: make(chan struct{})}
+}
+
+func (e *otlpExporter) export(s Snapshot) error {
+	var (
+		body        []byte
+		contentType string
+	)
+	if e.params.Encoding == OTLPJSON {
+		body, contentType = e.encodeJSON(s), "application/json"
+	} else {
+		body, contentType = e.encodeProtobuf(s), "application/x-protobuf"
+	}
+
+	backoff := e.params.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, retryAfter, err := e.send(body, contentType)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= e.params.Retries {
+			return err
+		}
+
+		delay := backoff
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-e.stopCh:
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// send sends a request once and reports whether a failure may be temporary
+// and a delay requested by the endpoint before a retry.
+func (e *otlpExporter) send(body []byte, contentType string) (bool, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.params.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, e.params.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, fmt.Errorf("gometer: invalid otlp request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range e.params.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.params.Client.Do(req)
+	if err != nil {
+		return true, 0, fmt.Errorf("gometer: otlp export failed: %v", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, 0, nil
+	}
+	err = fmt.Errorf("gometer: otlp export failed: %s", resp.Status)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true, parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()), err
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true, 0, err
+	default:
+		return false, 0, err
+	}
+}
+
+// parseRetryAfter returns a delay of the Retry-After header given in seconds
+// or as an HTTP date, it's 0 if the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+func (e *otlpExporter) startTime(c CounterEntry) time.Time {
+	if !c.Created.IsZero() {
+		return c.Created
+	}
+	return e.start
+}
+
+func (e *otlpExporter) resourceKeys() []string {
+	keys := make([]string, 0, len(e.params.Resource))
+	for k := range e.params.Resource {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encodeJSON encodes an ExportMetricsServiceRequest in the OTLP/JSON format,
+// where 64-bit integers are strings and enums are numbers.
+func (e *otlpExporter) encodeJSON(s Snapshot) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString(`{"resourceMetrics":[{"resource":{"attributes":[`)
+	for i, k := range e.resourceKeys() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`{"key":`)
+		writeJSONString(&buf, k)
+		buf.WriteString(`,"value":{"stringValue":`)
+		writeJSONString(&buf, e.params.Resource[k])
+		buf.WriteString(`}}`)
+	}
+	buf.WriteString(`]},"scopeMetrics":[{"scope":{"name":`)
+	writeJSONString(&buf, otlpInstrumentationScope)
+	buf.WriteString(`},"metrics":[`)
+
+	ts := strconv.FormatInt(s.Time.UnixNano(), 10)
+	for i, c := range s.Counters {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`{"name":`)
+		writeJSONString(&buf, c.Name)
+
+		switch c.Kind {
+		case KindMonotonic:
+			fmt.Fprintf(&buf, `,"sum":{"dataPoints":[{"startTimeUnixNano":"%d","timeUnixNano":"%s","asInt":"%d"}],"aggregationTemporality":2,"isMonotonic":true}}`,
+				e.startTime(c).UnixNano(), ts, c.Counter.Get())
+		case KindGauge:
+			fmt.Fprintf(&buf, `,"gauge":{"dataPoints":[{"timeUnixNano":"%s","asDouble":%s}]}}`, ts, otlpJSONDouble(c.Gauge))
+		default:
+			fmt.Fprintf(&buf, `,"gauge":{"dataPoints":[{"timeUnixNano":"%s","asInt":"%d"}]}}`, ts, c.Counter.Get())
+		}
+	}
+	buf.WriteString(`]}]}]}`)
+
+	return buf.Bytes()
+}
+
+// otlpJSONDouble formats a double as the protobuf JSON mapping does.
+func otlpJSONDouble(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return `"NaN"`
+	case math.IsInf(v, 1):
+		return `"Infinity"`
+	case math.IsInf(v, -1):
+		return `"-Infinity"`
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// Field numbers of OTLP protobuf messages.
+const (
+	otlpRequestResourceMetrics = 1
+
+	otlpResourceMetricsResource     = 1
+	otlpResourceMetricsScopeMetrics = 2
+	otlpResourceAttributes          = 1
+
+	otlpKeyValueKey         = 1
+	otlpKeyValueValue       = 2
+	otlpAnyValueStringValue = 1
+
+	otlpScopeMetricsScope   = 1
+	otlpScopeMetricsMetrics = 2
+	otlpScopeName           = 1
+
+	otlpMetricName  = 1
+	otlpMetricGauge = 5
+	otlpMetricSum   = 7
+
+	otlpGaugeDataPoints        = 1
+	otlpSumDataPoints          = 1
+	otlpSumTemporality         = 2
+	otlpSumIsMonotonic         = 3
+	otlpTemporalityCumulative  = 2
+	otlpDataPointStartTimeNano = 2
+	otlpDataPointTimeNano      = 3
+	otlpDataPointAsDouble      = 4
+	otlpDataPointAsInt         = 6
+)
+
+// encodeProtobuf encodes an ExportMetricsServiceRequest as a protobuf message.
+func (e *otlpExporter) encodeProtobuf(s Snapshot) []byte {
+	var resource []byte
+	for _, k := range e.resourceKeys() {
+		var value, kv []byte
+		value = protoAppendString(value, otlpAnyValueStringValue, e.params.Resource[k])
+		kv = protoAppendString(kv, otlpKeyValueKey, k)
+		kv = protoAppendBytes(kv, otlpKeyValueValue, value)
+		resource = protoAppendBytes(resource, otlpResourceAttributes, kv)
+	}
+
+	var scope []byte
+	scope = protoAppendString(scope, otlpScopeName, otlpInstrumentationScope)
+
+	var scopeMetrics []byte
+	scopeMetrics = protoAppendBytes(scopeMetrics, otlpScopeMetricsScope, scope)
+
+	ts := uint64(s.Time.UnixNano())
+	for _, c := range s.Counters {
+		var point, data, metric []byte
+		metric = protoAppendString(metric, otlpMetricName, c.Name)
+
+		switch c.Kind {
+		case KindMonotonic:
+			point = protoAppendFixed64(point, otlpDataPointStartTimeNano, uint64(e.startTime(c).UnixNano()))
+			point = protoAppendFixed64(point, otlpDataPointTimeNano, ts)
+			point = protoAppendFixed64(point, otlpDataPointAsInt, uint64(c.Counter.Get()))
+			data = protoAppendBytes(data, otlpSumDataPoints, point)
+			data = protoAppendVarint(data, otlpSumTemporality, otlpTemporalityCumulative)
+			data = protoAppendVarint(data, otlpSumIsMonotonic, 1)
+			metric = protoAppendBytes(metric, otlpMetricSum, data)
+		case KindGauge:
+			point = protoAppendFixed64(point, otlpDataPointTimeNano, ts)
+			point = protoAppendFixed64(point, otlpDataPointAsDouble, math.Float64bits(c.Gauge))
+			data = protoAppendBytes(data, otlpGaugeDataPoints, point)
+			metric = protoAppendBytes(metric, otlpMetricGauge, data)
+		default:
+			point = protoAppendFixed64(point, otlpDataPointTimeNano, ts)
+			point = protoAppendFixed64(point, otlpDataPointAsInt, uint64(c.Counter.Get()))
+			data = protoAppendBytes(data, otlpGaugeDataPoints, point)
+			metric = protoAppendBytes(metric, otlpMetricGauge, data)
+		}
+		scopeMetrics = protoAppendBytes(scopeMetrics, otlpScopeMetricsMetrics, metric)
+	}
+
+	var resourceMetrics []byte
+	resourceMetrics = protoAppendBytes(resourceMetrics, otlpResourceMetricsResource, resource)
+	resourceMetrics = protoAppendBytes(resourceMetrics, otlpResourceMetricsScopeMetrics, scopeMetrics)
+
+	var req []byte
+	return protoAppendBytes(req, otlpRequestResourceMetrics, resourceMetrics)
+}
+
+// Wire types of protobuf.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+func protoAppendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func protoAppendTag(b []byte, field, wireType int) []byte {
+	return protoAppendUvarint(b, uint64(field<<3|wireType))
+}
+
+func protoAppendVarint(b []byte, field int, v uint64) []byte {
+	b = protoAppendTag(b, field, protoVarint)
+	return protoAppendUvarint(b, v)
+}
+
+func protoAppendFixed64(b []byte, field int, v uint64) []byte {
+	b = protoAppendTag(b, field, protoFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func protoAppendBytes(b []byte, field int, data []byte) []byte {
+	b = protoAppendTag(b, field, protoBytes)
+	b = protoAppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func protoAppendString(b []byte, field int, s string) []byte {
+	return protoAppendBytes(b, field, []byte(s))
+}
@@ -0,0 +1,294 @@
+package gometer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// otlpCollector is a stand-in of an OTLP/HTTP collector that records requests
+// and responds with statuses in order, the last one is repeated.
+// Responses have the Retry-After header if retryAfter is set.
+type otlpCollector struct {
+	mu         sync.Mutex
+	statuses   []int
+	retryAfter string
+	requests   []*http.Request
+	bodies     [][]byte
+}
+
+func (c *otlpCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, r)
+	c.bodies = append(c.bodies, body)
+
+	status := http.StatusOK
+	if len(c.statuses) > 0 {
+		status = c.statuses[0]
+		if len(c.statuses) > 1 {
+			c.statuses = c.statuses[1:]
+		}
+	}
+	if c.retryAfter != "" {
+		w.Header().Set("Retry-After", c.retryAfter)
+	}
+	w.WriteHeader(status)
+}
+
+func (c *otlpCollector) requestsNum() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.requests)
+}
+
+func newOTLPTestMetrics() *DefaultMetrics {
+	metrics := New()
+	metrics.GetMonotonic("http.requests").Inc()
+	metrics.Get("queue").Set(-3)
+	metrics.GaugeFunc("load", func() float64 { return 0.5 })
+	return metrics
+}
+
+func TestOTLPExporterJSON(t *testing.T) {
+	collector := &otlpCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	StartOTLPExporter(newOTLPTestMetrics(), OTLPExporterParams{
+		Endpoint:       server.URL + "/v1/metrics",
+		Encoding:       OTLPJSON,
+		Resource:       map[string]string{"service.name": "app"},
+		Headers:        map[string]string{"Authorization": "token"},
+		UpdateInterval: time.Hour,
+	}).Stop()
+
+	require.Len(t, collector.requests, 1)
+	r := collector.requests[0]
+	assert.Equal(t, "/v1/metrics", r.URL.Path)
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	assert.Equal(t, "token", r.Header.Get("Authorization"))
+
+	var req struct {
+		ResourceMetrics []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string
+					Value struct{ StringValue string }
+				}
+			}
+			ScopeMetrics []struct {
+				Scope   struct{ Name string }
+				Metrics []struct {
+					Name  string
+					Gauge *struct {
+						DataPoints []struct {
+							TimeUnixNano string
+							AsInt        string
+							AsDouble     *float64
+						}
+					}
+					Sum *struct {
+						DataPoints []struct {
+							StartTimeUnixNano string
+							AsInt             string
+						}
+						AggregationTemporality int
+						IsMonotonic            bool
+					}
+				}
+			}
+		}
+	}
+	require.Nil(t, json.Unmarshal(collector.bodies[0], &req))
+	require.Len(t, req.ResourceMetrics, 1)
+	rm := req.ResourceMetrics[0]
+	require.Len(t, rm.Resource.Attributes, 1)
+	assert.Equal(t, "service.name", rm.Resource.Attributes[0].Key)
+	assert.Equal(t, "app", rm.Resource.Attributes[0].Value.StringValue)
+	require.Len(t, rm.ScopeMetrics, 1)
+	assert.Equal(t, otlpInstrumentationScope, rm.ScopeMetrics[0].Scope.Name)
+
+	metrics := rm.ScopeMetrics[0].Metrics
+	require.Len(t, metrics, 3)
+	assert.Equal(t, "http.requests", metrics[0].Name)
+	require.NotNil(t, metrics[0].Sum)
+	assert.Equal(t, "1", metrics[0].Sum.DataPoints[0].AsInt)
+	assert.NotEqual(t, "0", metrics[0].Sum.DataPoints[0].StartTimeUnixNano)
+	assert.Equal(t, 2, metrics[0].Sum.AggregationTemporality)
+	assert.True(t, metrics[0].Sum.IsMonotonic)
+	assert.Equal(t, "load", metrics[1].Name)
+	require.NotNil(t, metrics[1].Gauge)
+	assert.Equal(t, 0.5, *metrics[1].Gauge.DataPoints[0].AsDouble)
+	assert.Equal(t, "queue", metrics[2].Name)
+	require.NotNil(t, metrics[2].Gauge)
+	assert.Equal(t, "-3", metrics[2].Gauge.DataPoints[0].AsInt)
+}
+
+// protoFields decodes a protobuf message into values of its fields,
+// length-delimited values are []byte, others are uint64.
+func protoFields(t *testing.T, data []byte) map[int][]interface{} {
+	fields := make(map[int][]interface{})
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		require.True(t, n > 0)
+		data = data[n:]
+
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case protoVarint:
+			v, n := binary.Uvarint(data)
+			require.True(t, n > 0)
+			fields[field] = append(fields[field], v)
+			data = data[n:]
+		case protoFixed64:
+			fields[field] = append(fields[field], binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		case protoBytes:
+			l, n := binary.Uvarint(data)
+			require.True(t, n > 0)
+			fields[field] = append(fields[field], data[n:n+int(l)])
+			data = data[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+	return fields
+}
+
+func protoMessage(t *testing.T, fields map[int][]interface{}, field, i int) map[int][]interface{} {
+	require.True(t, len(fields[field]) > i, "field %d", field)
+	return protoFields(t, fields[field][i].([]byte))
+}
+
+func TestOTLPExporterProtobuf(t *testing.T) {
+	collector := &otlpCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	StartOTLPExporter(newOTLPTestMetrics(), OTLPExporterParams{
+		Endpoint:       server.URL,
+		Resource:       map[string]string{"service.name": "app"},
+		UpdateInterval: time.Hour,
+	}).Stop()
+
+	require.Len(t, collector.requests, 1)
+	assert.Equal(t, "application/x-protobuf", collector.requests[0].Header.Get("Content-Type"))
+
+	req := protoFields(t, collector.bodies[0])
+	rm := protoMessage(t, req, otlpRequestResourceMetrics, 0)
+	attr := protoMessage(t, protoMessage(t, rm, otlpResourceMetricsResource, 0), otlpResourceAttributes, 0)
+	assert.Equal(t, "service.name", string(attr[otlpKeyValueKey][0].([]byte)))
+	assert.Equal(t, "app", string(protoMessage(t, attr, otlpKeyValueValue, 0)[otlpAnyValueStringValue][0].([]byte)))
+
+	sm := protoMessage(t, rm, otlpResourceMetricsScopeMetrics, 0)
+	require.Len(t, sm[otlpScopeMetricsMetrics], 3)
+
+	requests := protoMessage(t, sm, otlpScopeMetricsMetrics, 0)
+	assert.Equal(t, "http.requests", string(requests[otlpMetricName][0].([]byte)))
+	sum := protoMessage(t, requests, otlpMetricSum, 0)
+	assert.Equal(t, uint64(otlpTemporalityCumulative), sum[otlpSumTemporality][0])
+	assert.Equal(t, uint64(1), sum[otlpSumIsMonotonic][0])
+	point := protoMessage(t, sum, otlpSumDataPoints, 0)
+	assert.Equal(t, uint64(1), point[otlpDataPointAsInt][0])
+	assert.True(t, point[otlpDataPointStartTimeNano][0].(uint64) <= point[otlpDataPointTimeNano][0].(uint64))
+
+	load := protoMessage(t, sm, otlpScopeMetricsMetrics, 1)
+	point = protoMessage(t, protoMessage(t, load, otlpMetricGauge, 0), otlpGaugeDataPoints, 0)
+	assert.Equal(t, 0.5, math.Float64frombits(point[otlpDataPointAsDouble][0].(uint64)))
+
+	queue := protoMessage(t, sm, otlpScopeMetricsMetrics, 2)
+	point = protoMessage(t, protoMessage(t, queue, otlpMetricGauge, 0), otlpGaugeDataPoints, 0)
+	assert.Equal(t, int64(-3), int64(point[otlpDataPointAsInt][0].(uint64)))
+}
+
+func TestOTLPExporterRetry(t *testing.T) {
+	for _, tCase := range []struct {
+		statuses []int
+		requests int
+		failed   bool
+	}{
+		{statuses: []int{503, 429, 200}, requests: 3},
+		{statuses: []int{503}, requests: 3, failed: true},
+		{statuses: []int{400}, requests: 1, failed: true},
+	} {
+		collector := &otlpCollector{statuses: tCase.statuses}
+		server := httptest.NewServer(collector)
+
+		e := newOTLPExporter(OTLPExporterParams{
+			Endpoint:     server.URL,
+			Retries:      2,
+			RetryBackoff: time.Millisecond,
+		})
+		err := e.export(newOTLPTestMetrics().Snapshot())
+		server.Close()
+
+		assert.Len(t, collector.requests, tCase.requests, tCase.statuses)
+		assert.Equal(t, tCase.failed, err != nil, tCase.statuses)
+	}
+}
+
+func TestOTLPExporterRetryAfter(t *testing.T) {
+	collector := &otlpCollector{statuses: []int{503}, retryAfter: "3600"}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	var errs []error
+	exporter := StartOTLPExporter(newOTLPTestMetrics(), OTLPExporterParams{
+		Endpoint:       server.URL,
+		UpdateInterval: time.Millisecond,
+		Retries:        1,
+		RetryBackoff:   time.Millisecond,
+		ErrorHandler:   func(err error) { errs = append(errs, err) },
+	})
+	for collector.requestsNum() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the exporter waits for an hour, but Stop interrupts the wait
+	// and exports after Stop aren't retried.
+	start := time.Now()
+	exporter.Stop()
+	assert.True(t, time.Since(start) < time.Minute)
+	assert.True(t, len(errs) >= 2)
+	assert.Equal(t, len(errs), collector.requestsNum())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, 2*time.Second, parseRetryAfter("2", now))
+	assert.Equal(t, time.Minute, parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+}
+
+func TestOTLPExporterTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	var errs []error
+	StartOTLPExporter(newOTLPTestMetrics(), OTLPExporterParams{
+		Endpoint:       server.URL,
+		UpdateInterval: time.Hour,
+		Timeout:        10 * time.Millisecond,
+		ErrorHandler:   func(err error) { errs = append(errs, err) },
+	}).Stop()
+	assert.Len(t, errs, 1)
+}
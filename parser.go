@@ -0,0 +1,137 @@
+package gometer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parser parses metrics representation produced by a Formatter.
+//
+// Integer values are parsed as KindCounter entries, other numbers as KindGauge entries.
+// Time of a parsed snapshot is zero unless it's a part of the representation.
+type Parser interface {
+	Parse(data []byte) (Snapshot, error)
+}
+
+// NewParser returns a parser of metrics formatted by NewFormatter with the same lineSeparator.
+func NewParser(lineSeparator string) Parser {
+	return &defaultParser{
+		lineSeparator: lineSeparator,
+	}
+}
+
+// NewJSONParser returns a parser of metrics formatted as a JSON map, e.g. by GetJSON()
+// or NewJSONFormatter(). Metrics wrapped into an envelope are parsed as well,
+// with the time of the snapshot taken from its "ts". Null values are parsed as NaN gauges.
+func NewJSONParser() Parser {
+	return &jsonParser{}
+}
+
+// ParseAny parses metrics formatted either as a JSON map or by NewFormatter with lineSeparator.
+func ParseAny(data []byte, lineSeparator string) (Snapshot, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return NewJSONParser().Parse(data)
+	}
+	return NewParser(lineSeparator).Parse(data)
+}
+
+type defaultParser struct {
+	lineSeparator string
+}
+
+func (p *defaultParser) Parse(data []byte) (Snapshot, error) {
+	if p.lineSeparator == "" {
+		return Snapshot{}, errors.New("gometer: empty line separator")
+	}
+
+	var s SortedCounters
+	for _, line := range strings.Split(string(data), p.lineSeparator) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		i := strings.LastIndex(line, " = ")
+		if i < 0 {
+			return Snapshot{}, fmt.Errorf("gometer: invalid metrics line %q", line)
+		}
+
+		e, err := parseEntry(line[:i], strings.TrimSpace(line[i+len(" = "):]))
+		if err != nil {
+			return Snapshot{}, err
+		}
+		s = append(s, e)
+	}
+	return newParsedSnapshot(s), nil
+}
+
+var _ Parser = (*defaultParser)(nil)
+
+type jsonParser struct {
+}
+
+func (p *jsonParser) Parse(data []byte) (Snapshot, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Snapshot{}, fmt.Errorf("gometer: invalid json metrics: %v", err)
+	}
+	var t time.Time
+	if metrics, ok := raw["metrics"]; ok && bytes.HasPrefix(metrics, []byte("{")) {
+		if ts, ok := raw["ts"]; ok {
+			ms, err := strconv.ParseInt(string(ts), 10, 64)
+			if err != nil {
+				return Snapshot{}, fmt.Errorf("gometer: invalid json metrics timestamp: %q", ts)
+			}
+			t = time.Unix(0, ms*int64(time.Millisecond))
+		}
+
+		raw = nil
+		if err := json.Unmarshal(metrics, &raw); err != nil {
+			return Snapshot{}, fmt.Errorf("gometer: invalid json metrics: %v", err)
+		}
+	}
+
+	s := make(SortedCounters, 0, len(raw))
+	for name, value := range raw {
+		if string(value) == "null" {
+			s = append(s, newGaugeEntry(name, math.NaN()))
+			continue
+		}
+		e, err := parseEntry(name, string(value))
+		if err != nil {
+			return Snapshot{}, err
+		}
+		s = append(s, e)
+	}
+	snapshot := newParsedSnapshot(s)
+	snapshot.Time = t
+	return snapshot, nil
+}
+
+var _ Parser = (*jsonParser)(nil)
+
+func parseEntry(name, value string) (CounterEntry, error) {
+	if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+		c := &Counter{}
+		c.Set(v)
+		return CounterEntry{Name: name, Counter: c, Kind: KindCounter}, nil
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return CounterEntry{}, fmt.Errorf("gometer: invalid value of %q: %q", name, value)
+	}
+	return newGaugeEntry(name, v), nil
+}
+
+func newParsedSnapshot(s SortedCounters) Snapshot {
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Name < s[j].Name
+	})
+	return Snapshot{Counters: s}
+}
@@ -0,0 +1,140 @@
+package gometer
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// randomCounters is a quick.Generator of counters which names
+// don't clash with separators used by tests.
+type randomCounters SortedCounters
+
+func (randomCounters) Generate(r *rand.Rand, size int) reflect.Value {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789._-"
+
+	names := make(map[string]struct{})
+	var s SortedCounters
+	for i := 0; i < r.Intn(size+1); i++ {
+		name := make([]byte, 1+r.Intn(16))
+		for j := range name {
+			name[j] = alphabet[r.Intn(len(alphabet))]
+		}
+		if _, ok := names[string(name)]; ok {
+			continue
+		}
+		names[string(name)] = struct{}{}
+
+		if r.Intn(2) == 0 {
+			s = append(s, newGaugeEntry(string(name), r.NormFloat64()*math.Pow(10, float64(r.Intn(20)-10))))
+		} else {
+			c := &Counter{}
+			c.Set(r.Int63() - r.Int63())
+			s = append(s, CounterEntry{Name: string(name), Counter: c})
+		}
+	}
+	return reflect.ValueOf(randomCounters(newParsedSnapshot(s).Counters))
+}
+
+func checkRoundTrip(t *testing.T, f Formatter, p Parser) {
+	err := quick.Check(func(counters randomCounters) bool {
+		data := f.Format(SortedCounters(counters))
+		s, err := p.Parse(data)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		if len(s.Counters) != len(counters) {
+			return false
+		}
+		for i, e := range s.Counters {
+			if e.Name != counters[i].Name || e.Value() != counters[i].Value() {
+				return false
+			}
+		}
+		return string(f.Format(s.Counters)) == string(data)
+	}, nil)
+	assert.Nil(t, err)
+}
+
+func TestParserRoundTrip(t *testing.T) {
+	for _, sep := range []string{"\n", ",", ";", "\r\n", "|"} {
+		checkRoundTrip(t, NewFormatter(sep), NewParser(sep))
+	}
+}
+
+func TestJSONParserRoundTrip(t *testing.T) {
+	checkRoundTrip(t, NewJSONFormatter(JSONFormatterParams{}), NewJSONParser())
+}
+
+func TestJSONParserEnvelope(t *testing.T) {
+	s, err := NewJSONParser().Parse([]byte(`{"ts": 1, "meta": {"host": "a"}, "metrics": {"a\"b": 1, "nan": null}}`))
+	require.Nil(t, err)
+	require.Len(t, s.Counters, 2)
+	assert.Equal(t, `a"b`, s.Counters[0].Name)
+	assert.Equal(t, float64(1), s.Counters[0].Value())
+	assert.Equal(t, KindGauge, s.Counters[1].Kind)
+	assert.True(t, math.IsNaN(s.Counters[1].Value()))
+	assert.Equal(t, time.Unix(0, int64(time.Millisecond)), s.Time)
+
+	// the time of a snapshot survives a round trip.
+	now := time.Unix(1600000000, 123e6)
+	data := NewJSONFormatter(JSONFormatterParams{
+		Timestamp: true,
+		Now:       func() time.Time { return now },
+	}).Format(s.Counters)
+	s, err = NewJSONParser().Parse(data)
+	require.Nil(t, err)
+	assert.True(t, now.Equal(s.Time))
+
+	_, err = NewJSONParser().Parse([]byte(`{"ts": "now", "metrics": {}}`))
+	assert.NotNil(t, err)
+
+	// a metric named "metrics" isn't an envelope.
+	s, err = NewJSONParser().Parse([]byte(`{"metrics": 2}`))
+	require.Nil(t, err)
+	require.Len(t, s.Counters, 1)
+	assert.Equal(t, "metrics", s.Counters[0].Name)
+}
+
+func TestParser(t *testing.T) {
+	s, err := NewParser("\n").Parse([]byte("b = 0.5\na = -3\n\nc = 1e+21\n"))
+	require.Nil(t, err)
+	require.Len(t, s.Counters, 3)
+
+	assert.Equal(t, "a", s.Counters[0].Name)
+	assert.Equal(t, KindCounter, s.Counters[0].Kind)
+	assert.Equal(t, int64(-3), s.Counters[0].Counter.Get())
+	assert.Equal(t, "b", s.Counters[1].Name)
+	assert.Equal(t, KindGauge, s.Counters[1].Kind)
+	assert.Equal(t, 0.5, s.Counters[1].Value())
+	assert.Equal(t, 1e21, s.Counters[2].Value())
+
+	for _, data := range []string{"a == 1", "a = b", "a"} {
+		_, err = NewParser("\n").Parse([]byte(data))
+		assert.NotNil(t, err, data)
+	}
+	_, err = NewParser("").Parse([]byte("a = 1"))
+	assert.NotNil(t, err)
+}
+
+func TestParseAny(t *testing.T) {
+	s, err := ParseAny([]byte(` {"a": 1, "b": 2.5}`), "\n")
+	require.Nil(t, err)
+	require.Len(t, s.Counters, 2)
+	assert.Equal(t, 2.5, s.Counters[1].Value())
+
+	s, err = ParseAny([]byte("a = 1;b = 2;"), ";")
+	require.Nil(t, err)
+	require.Len(t, s.Counters, 2)
+	assert.Equal(t, float64(2), s.Counters[1].Value())
+
+	_, err = ParseAny([]byte(`{"a": "b"}`), "\n")
+	assert.NotNil(t, err)
+}
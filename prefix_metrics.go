@@ -16,6 +16,26 @@ func (m *PrefixMetrics) Get(counterName string) *Counter {
 	return m.Metrics.Get(m.prefix + counterName)
 }
 
+// GetMonotonic calls underlying Metrics GetMonotonic method with prefixed counterName.
+func (m *PrefixMetrics) GetMonotonic(counterName string) *MonotonicCounter {
+	return m.Metrics.GetMonotonic(m.prefix + counterName)
+}
+
+// GaugeFunc calls underlying Metrics GaugeFunc method with prefixed name.
+func (m *PrefixMetrics) GaugeFunc(name string, f func() float64) {
+	m.Metrics.GaugeFunc(m.prefix+name, f)
+}
+
+// CounterFunc calls underlying Metrics CounterFunc method with prefixed name.
+func (m *PrefixMetrics) CounterFunc(name string, f func() int64) {
+	m.Metrics.CounterFunc(m.prefix+name, f)
+}
+
+// MonotonicFunc calls underlying Metrics MonotonicFunc method with prefixed name.
+func (m *PrefixMetrics) MonotonicFunc(name string, f func() int64) {
+	m.Metrics.MonotonicFunc(m.prefix+name, f)
+}
+
 // WithPrefix returns new PrefixMetrics with extended prefix.
 func (m *PrefixMetrics) WithPrefix(prefix string, v ...interface{}) *PrefixMetrics {
 	return &PrefixMetrics{
@@ -33,3 +33,27 @@ func TestPrefixMetricsTwice(t *testing.T) {
 	c := prefixMetrics1.Get("counter")
 	assert.True(t, c == originalMetrics.Get("prefix1.prefix2.errors.counter"))
 }
+
+func TestPrefixMetricsGetMonotonic(t *testing.T) {
+	originalMetrics := New()
+	prefixMetrics := originalMetrics.WithPrefix("data.")
+
+	c := prefixMetrics.GetMonotonic("requests")
+	assert.True(t, c == originalMetrics.GetMonotonic("data.requests"))
+}
+
+func TestPrefixMetricsFuncs(t *testing.T) {
+	originalMetrics := New()
+	prefixMetrics := originalMetrics.WithPrefix("pool.")
+
+	prefixMetrics.CounterFunc("idle", func() int64 { return 3 })
+	prefixMetrics.GaugeFunc("load", func() float64 { return 0.5 })
+	prefixMetrics.MonotonicFunc("opened", func() int64 { return 7 })
+
+	b := originalMetrics.GetJSON(func(string) bool { return true })
+	assert.JSONEq(t, `{"pool.idle": 3, "pool.load": 0.5, "pool.opened": 7}`, string(b))
+
+	e, ok := originalMetrics.Snapshot().Get("pool.opened")
+	require.True(t, ok)
+	assert.Equal(t, KindMonotonic, e.Kind)
+}
@@ -0,0 +1,340 @@
+package gometer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProcessMetricsParams represents params of process metrics collector.
+//
+// Prefix is added to all metric names, "process." is used if it's empty.
+// ProcDir is a directory of the process in procfs, "/proc/self" is used if it's empty.
+// ClockTicks is a number of clock ticks per second (USER_HZ), 100 is used if it's zero.
+// ErrorHandler allows to handle errors of reading procfs, they're ignored if it's nil.
+type ProcessMetricsParams struct {
+	Prefix       string
+	ProcDir      string
+	ClockTicks   int64
+	ErrorHandler func(err error)
+}
+
+// RegisterProcessMetrics registers metrics of the current process read from procfs in m.
+//
+// Registered metrics are: memory.rss_bytes, memory.vms_bytes, threads and fds gauges,
+// cpu.user_ns, cpu.system_ns and, if <ProcDir>/io is readable, io.rchar, io.wchar,
+// io.read_bytes and io.write_bytes monotonic counters. They are refreshed on each write.
+//
+// An error is returned if procfs isn't available, e.g. on non-Linux systems.
+// Errors of further reads are reported to params.ErrorHandler and the last read
+// values are used instead.
+func RegisterProcessMetrics(m Metrics, params ProcessMetricsParams) error {
+	if params.Prefix == "" {
+		params.Prefix = "process."
+	}
+	if params.ProcDir == "" {
+		params.ProcDir = "/proc/self"
+	}
+	if params.ClockTicks == 0 {
+		params.ClockTicks = 100
+	}
+
+	c := &processCollector{dir: params.ProcDir, errorHandler: params.ErrorHandler}
+	c.withIO = c.readIO(new(procIO)) == nil
+	s, err := c.read()
+	if err != nil {
+		return err
+	}
+	c.last = s
+
+	pm := m.WithPrefix(params.Prefix)
+	tick := int64(time.Second) / params.ClockTicks
+
+	pm.MonotonicFunc("cpu.user_ns", func() int64 {
+		return c.sample().stat.utime * tick
+	})
+	pm.MonotonicFunc("cpu.system_ns", func() int64 {
+		return c.sample().stat.stime * tick
+	})
+	pm.GaugeFunc("memory.rss_bytes", func() float64 {
+		return float64(c.sample().status.vmRSS)
+	})
+	pm.GaugeFunc("memory.vms_bytes", func() float64 {
+		return float64(c.sample().status.vmSize)
+	})
+	pm.GaugeFunc("threads", func() float64 {
+		return float64(c.sample().status.threads)
+	})
+	pm.GaugeFunc("fds", func() float64 {
+		return float64(c.sample().fds)
+	})
+
+	if c.withIO {
+		pm.MonotonicFunc("io.rchar", func() int64 {
+			return c.sample().io.rchar
+		})
+		pm.MonotonicFunc("io.wchar", func() int64 {
+			return c.sample().io.wchar
+		})
+		pm.MonotonicFunc("io.read_bytes", func() int64 {
+			return c.sample().io.readBytes
+		})
+		pm.MonotonicFunc("io.write_bytes", func() int64 {
+			return c.sample().io.writeBytes
+		})
+	}
+	return nil
+}
+
+type processCollector struct {
+	dir          string
+	withIO       bool
+	errorHandler func(err error)
+
+	mu   sync.Mutex
+	last *processSample
+}
+
+type processSample struct {
+	time   time.Time
+	stat   procStat
+	status procStatus
+	io     procIO
+	fds    int64
+}
+
+// sample returns process values reading them if the last sample is outdated.
+// If reading fails, the error is reported and the last values are used
+// until the next attempt after collectorSampleTTL.
+func (c *processCollector) sample() *processSample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.last.time) < collectorSampleTTL {
+		return c.last
+	}
+
+	s, err := c.read()
+	if err != nil {
+		if c.errorHandler != nil {
+			c.errorHandler(err)
+		}
+		last := *c.last
+		last.time = time.Now()
+		c.last = &last
+		return c.last
+	}
+	c.last = s
+	return s
+}
+
+func (c *processCollector) read() (*processSample, error) {
+	s := &processSample{time: time.Now()}
+
+	data, err := ioutil.ReadFile(filepath.Join(c.dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	if s.stat, err = parseProcStat(data); err != nil {
+		return nil, err
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(c.dir, "status"))
+	if err != nil {
+		return nil, err
+	}
+	if s.status, err = parseProcStatus(data); err != nil {
+		return nil, err
+	}
+
+	if s.fds, err = countFDs(filepath.Join(c.dir, "fd")); err != nil {
+		return nil, err
+	}
+
+	if c.withIO {
+		if err = c.readIO(&s.io); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (c *processCollector) readIO(io *procIO) error {
+	data, err := ioutil.ReadFile(filepath.Join(c.dir, "io"))
+	if err != nil {
+		return err
+	}
+	*io, err = parseProcIO(data)
+	return err
+}
+
+// countFDs counts entries of a fd directory. The descriptor used to read
+// the directory isn't counted if the directory is the fd table of the current process.
+func countFDs(dir string) (int64, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return 0, err
+	}
+
+	n := int64(len(names))
+	own := strconv.FormatUint(uint64(f.Fd()), 10)
+	for _, name := range names {
+		if name != own {
+			continue
+		}
+		// the entry of the own descriptor is a link to the directory itself.
+		dirInfo, err := f.Stat()
+		if err != nil {
+			break
+		}
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && os.SameFile(info, dirInfo) {
+			n--
+		}
+		break
+	}
+	return n, nil
+}
+
+// procStat represents the used fields of /proc/[pid]/stat.
+type procStat struct {
+	utime int64
+	stime int64
+}
+
+// parseProcStat parses /proc/[pid]/stat, see proc(5).
+func parseProcStat(data []byte) (procStat, error) {
+	// comm is enclosed in parentheses and may contain spaces and parentheses itself.
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return procStat{}, errors.New("gometer: invalid proc stat: no command name")
+	}
+
+	// fields start from the 3rd one (state).
+	fields := strings.Fields(string(data[i+1:]))
+	const (
+		utimeField = 14
+		stimeField = 15
+	)
+	if len(fields) < stimeField-2 {
+		return procStat{}, fmt.Errorf("gometer: invalid proc stat: %d fields", len(fields)+2)
+	}
+
+	var (
+		s   procStat
+		err error
+	)
+	for _, f := range []struct {
+		num int
+		val *int64
+	}{
+		{num: utimeField, val: &s.utime},
+		{num: stimeField, val: &s.stime},
+	} {
+		if *f.val, err = strconv.ParseInt(fields[f.num-3], 10, 64); err != nil {
+			return procStat{}, fmt.Errorf("gometer: invalid proc stat field %d: %v", f.num, err)
+		}
+	}
+	return s, nil
+}
+
+// procStatus represents the used fields of /proc/[pid]/status.
+type procStatus struct {
+	vmRSS   int64
+	vmSize  int64
+	threads int64
+}
+
+// parseProcStatus parses /proc/[pid]/status, see proc(5).
+func parseProcStatus(data []byte) (procStatus, error) {
+	var s procStatus
+	err := parseProcKeyValues(data, func(key, value string) error {
+		var (
+			dst  *int64
+			unit int64 = 1
+		)
+		switch key {
+		case "VmRSS":
+			dst, unit = &s.vmRSS, 1024
+		case "VmSize":
+			dst, unit = &s.vmSize, 1024
+		case "Threads":
+			dst = &s.threads
+		default:
+			return nil
+		}
+
+		v, err := strconv.ParseInt(strings.TrimSuffix(value, " kB"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("gometer: invalid proc status %s: %v", key, err)
+		}
+		*dst = v * unit
+		return nil
+	})
+	return s, err
+}
+
+// procIO represents /proc/[pid]/io.
+type procIO struct {
+	rchar      int64
+	wchar      int64
+	readBytes  int64
+	writeBytes int64
+}
+
+// parseProcIO parses /proc/[pid]/io, see proc(5).
+func parseProcIO(data []byte) (procIO, error) {
+	var s procIO
+	err := parseProcKeyValues(data, func(key, value string) error {
+		var dst *int64
+		switch key {
+		case "rchar":
+			dst = &s.rchar
+		case "wchar":
+			dst = &s.wchar
+		case "read_bytes":
+			dst = &s.readBytes
+		case "write_bytes":
+			dst = &s.writeBytes
+		default:
+			return nil
+		}
+
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("gometer: invalid proc io %s: %v", key, err)
+		}
+		*dst = v
+		return nil
+	})
+	return s, err
+}
+
+// parseProcKeyValues calls fn for each "key: value" line of data.
+func parseProcKeyValues(data []byte, fn func(key, value string) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		if err := fn(line[:i], strings.TrimSpace(line[i+1:])); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
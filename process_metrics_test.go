@@ -0,0 +1,159 @@
+package gometer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProcStat(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/proc/stat")
+	require.Nil(t, err)
+
+	s, err := parseProcStat(data)
+	require.Nil(t, err)
+	assert.Equal(t, procStat{utime: 1250, stime: 340}, s)
+
+	_, err = parseProcStat([]byte("4242 (app) S 1 2"))
+	assert.NotNil(t, err)
+	_, err = parseProcStat([]byte("4242 app"))
+	assert.NotNil(t, err)
+}
+
+func TestParseProcStatus(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/proc/status")
+	require.Nil(t, err)
+
+	s, err := parseProcStatus(data)
+	require.Nil(t, err)
+	assert.Equal(t, procStatus{vmRSS: 81920 * 1024, vmSize: 1228800 * 1024, threads: 12}, s)
+
+	_, err = parseProcStatus([]byte("VmRSS:\tlots kB\n"))
+	assert.NotNil(t, err)
+}
+
+func TestParseProcIO(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/proc/io")
+	require.Nil(t, err)
+
+	s, err := parseProcIO(data)
+	require.Nil(t, err)
+	assert.Equal(t, procIO{rchar: 3980, wchar: 1024, readBytes: 4096, writeBytes: 8192}, s)
+}
+
+func TestProcessMetricsFixture(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	require.Nil(t, RegisterProcessMetrics(metrics, ProcessMetricsParams{
+		Prefix:  "proc.",
+		ProcDir: "testdata/proc",
+	}))
+
+	b := metrics.GetJSON(func(string) bool { return true })
+	assert.JSONEq(t, `{
+		"proc.cpu.system_ns": 3400000000,
+		"proc.cpu.user_ns": 12500000000,
+		"proc.fds": 4,
+		"proc.io.rchar": 3980,
+		"proc.io.read_bytes": 4096,
+		"proc.io.wchar": 1024,
+		"proc.io.write_bytes": 8192,
+		"proc.memory.rss_bytes": 83886080,
+		"proc.memory.vms_bytes": 1258291200,
+		"proc.threads": 12
+	}`, string(b))
+
+	s := metrics.Snapshot()
+	for name, kind := range map[string]Kind{
+		"proc.cpu.user_ns":      KindMonotonic,
+		"proc.io.rchar":         KindMonotonic,
+		"proc.memory.rss_bytes": KindGauge,
+		"proc.fds":              KindGauge,
+	} {
+		e, ok := s.Get(name)
+		require.True(t, ok, name)
+		assert.Equal(t, kind, e.Kind, name)
+	}
+}
+
+func TestProcessMetricsReadError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gometer")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"stat", "status"} {
+		data, err := ioutil.ReadFile(filepath.Join("testdata/proc", name))
+		require.Nil(t, err)
+		require.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0644))
+	}
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "fd"), 0755))
+
+	var errs []error
+	metrics := New()
+	require.Nil(t, RegisterProcessMetrics(metrics, ProcessMetricsParams{
+		ProcDir:      dir,
+		ErrorHandler: func(err error) { errs = append(errs, err) },
+	}))
+	require.Nil(t, os.Remove(filepath.Join(dir, "stat")))
+	time.Sleep(collectorSampleTTL)
+
+	// the last values are used.
+	e, ok := metrics.Snapshot().Get("process.threads")
+	require.True(t, ok)
+	assert.Equal(t, float64(12), e.Value())
+	assert.Len(t, errs, 1)
+}
+
+func TestProcessMetricsSelf(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	err := RegisterProcessMetrics(metrics, ProcessMetricsParams{})
+	if runtime.GOOS != "linux" {
+		assert.NotNil(t, err)
+		return
+	}
+	require.Nil(t, err)
+
+	s := metrics.Snapshot()
+	for _, name := range []string{"process.memory.rss_bytes", "process.threads", "process.fds"} {
+		e, ok := s.Get(name)
+		require.True(t, ok, name)
+		assert.True(t, e.Value() > 0, name)
+	}
+}
+
+func TestCountFDs(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("procfs is only available on linux")
+	}
+
+	// the listing of dir includes its own descriptor, that countFDs excludes,
+	// and not the descriptor of countFDs, so both counts are equal.
+	dir, err := os.Open("/proc/self/fd")
+	require.Nil(t, err)
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	require.Nil(t, err)
+
+	n, err := countFDs("/proc/self/fd")
+	require.Nil(t, err)
+	assert.Equal(t, int64(len(names)), n)
+}
+
+func TestProcessMetricsNoProc(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "gometer")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NotNil(t, RegisterProcessMetrics(New(), ProcessMetricsParams{ProcDir: dir}))
+}
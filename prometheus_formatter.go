@@ -0,0 +1,130 @@
+package gometer
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NewPrometheusFormatter returns a formatter of the Prometheus text exposition format.
+//
+// Names are sanitized to match [a-zA-Z_:][a-zA-Z0-9_:]*, invalid characters
+// are replaced by '_'. Names that collide after sanitizing, e.g. "a.b" and "a_b",
+// are made unique by suffixes, see prometheusNames. Monotonic counters are exposed
+// as counters, gauges as gauges and other counters as untyped metrics.
+func NewPrometheusFormatter() Formatter {
+	return &prometheusFormatter{}
+}
+
+type prometheusFormatter struct {
+}
+
+func (f *prometheusFormatter) Format(counters SortedCounters) []byte {
+	var buf bytes.Buffer
+
+	names := prometheusNames(counters)
+	for i, c := range counters {
+		name := names[i]
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, prometheusType(c.Kind))
+		fmt.Fprintf(&buf, "%s %s\n", name, formatValue(c))
+	}
+
+	return buf.Bytes()
+}
+
+var _ Formatter = (*prometheusFormatter)(nil)
+
+func prometheusType(k Kind) string {
+	switch k {
+	case KindMonotonic:
+		return "counter"
+	case KindGauge:
+		return "gauge"
+	default:
+		return "untyped"
+	}
+}
+
+// prometheusLabelEscaper escapes label values of Prometheus and OpenMetrics text formats.
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusNames returns sanitized names of counters made unique by uniqueSuffixes.
+func prometheusNames(counters SortedCounters) []string {
+	names := make([]string, len(counters))
+	for i, c := range counters {
+		names[i] = SanitizePrometheusName(c.Name)
+	}
+	for i, suffix := range uniqueSuffixes(len(names), func(i int, suffix string) []string {
+		return []string{names[i] + suffix}
+	}) {
+		names[i] += suffix
+	}
+	return names
+}
+
+// uniqueSuffixes returns suffixes that make names of n metrics unique.
+//
+// occupied returns all names used by i-th metric with a suffix, e.g. names of
+// its samples. The first metric that occupies a name keeps an empty suffix, next
+// ones get the lowest suffix "_2", "_3" and so on that makes their names free,
+// so suffixes are stable for the same sorted metrics.
+func uniqueSuffixes(n int, occupied func(i int, suffix string) []string) []string {
+	owners := make(map[string]int)
+	for i := 0; i < n; i++ {
+		for _, name := range occupied(i, "") {
+			if _, ok := owners[name]; !ok {
+				owners[name] = i
+			}
+		}
+	}
+
+	suffixes := make([]string, n)
+	for i := range suffixes {
+		free := func(suffix string) bool {
+			for _, name := range occupied(i, suffix) {
+				if owner, ok := owners[name]; ok && owner != i {
+					return false
+				}
+			}
+			return true
+		}
+		if free("") {
+			continue
+		}
+		for k := 2; ; k++ {
+			suffix := "_" + strconv.Itoa(k)
+			if free(suffix) {
+				for _, name := range occupied(i, suffix) {
+					owners[name] = i
+				}
+				suffixes[i] = suffix
+				break
+			}
+		}
+	}
+	return suffixes
+}
+
+// SanitizePrometheusName converts a metric name to a valid Prometheus metric name.
+func SanitizePrometheusName(name string) string {
+	if name == "" {
+		return "_"
+	}
+
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,151 @@
+package gometer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultPushTimeout = 10 * time.Second
+
+// PushgatewayParams represents params of pushing metrics to a Prometheus Pushgateway.
+//
+// URL is a base URL of a Pushgateway, e.g. "http://localhost:9091".
+// Job and Grouping are grouping keys of pushed metrics, e.g. Grouping can contain "instance",
+// but not "job".
+// Method is http.MethodPut by default, that replaces all metrics of the group,
+// http.MethodPost replaces only metrics with the same names.
+// Username and Password are used for basic authentication if Username isn't empty.
+// Timeout limits every push, 10 seconds by default.
+// Client is used to send requests, http.DefaultClient is used if it's nil.
+// ErrorHandler allows to handle failed pushes of a pusher, they're ignored if it's nil.
+// NoFlushOnStop disables the final push when a pusher is stopped.
+type PushgatewayParams struct {
+	URL            string
+	Job            string
+	Grouping       map[string]string
+	Method         string
+	Username       string
+	Password       string
+	UpdateInterval time.Duration
+	Timeout        time.Duration
+	Client         *http.Client
+	ErrorHandler   func(err error)
+	NoFlushOnStop  bool
+}
+
+// Push pushes metrics of m in the Prometheus text format to a Pushgateway once,
+// e.g. at the end of a batch job.
+func Push(m Metrics, params PushgatewayParams) error {
+	if params.Job == "" {
+		return errors.New("gometer: pushgateway job is empty")
+	}
+	if _, ok := params.Grouping["job"]; ok {
+		return errors.New("gometer: pushgateway grouping key \"job\" conflicts with the job")
+	}
+	if params.Method == "" {
+		params.Method = http.MethodPut
+	}
+	if params.Timeout <= 0 {
+		params.Timeout = defaultPushTimeout
+	}
+	if params.Client == nil {
+		params.Client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), params.Timeout)
+	defer cancel()
+
+	body := NewPrometheusFormatter().Format(m.Snapshot().Counters)
+	req, err := http.NewRequest(params.Method, pushgatewayURL(params), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("gometer: invalid pushgateway request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	if params.Username != "" {
+		req.SetBasicAuth(params.Username, params.Password)
+	}
+
+	resp, err := params.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("gometer: pushgateway push failed: %v", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("gometer: pushgateway push failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// StartPushgatewayPusher starts a goroutine that pushes metrics of m to a Pushgateway
+// every params.UpdateInterval and once more when it's stopped.
+// If UpdateInterval isn't positive, metrics are pushed only when the pusher is stopped.
+// For more details see Push().
+func StartPushgatewayPusher(m Metrics, params PushgatewayParams) Stopper {
+	push := func() {
+		if err := Push(m, params); err != nil && params.ErrorHandler != nil {
+			params.ErrorHandler(err)
+		}
+	}
+	if params.UpdateInterval > 0 {
+		return startPeriodic(params.UpdateInterval, !params.NoFlushOnStop, push)
+	}
+
+	var stopOnce sync.Once
+	return &stopperFunc{stop: func() {
+		stopOnce.Do(func() {
+			if !params.NoFlushOnStop {
+				push()
+			}
+		})
+	}}
+}
+
+// pushgatewayURL returns a URL of a group: <URL>/metrics/job/<job>{/<label>/<value>}.
+// Values that can't be a path segment are encoded in base64.
+func pushgatewayURL(params PushgatewayParams) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSuffix(params.URL, "/"))
+	b.WriteString("/metrics")
+	writePushgatewayLabel(&b, "job", params.Job)
+
+	keys := make([]string, 0, len(params.Grouping))
+	for k := range params.Grouping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writePushgatewayLabel(&b, k, params.Grouping[k])
+	}
+	return b.String()
+}
+
+func writePushgatewayLabel(b *strings.Builder, name, value string) {
+	b.WriteByte('/')
+	if value == "" || strings.Contains(value, "/") {
+		b.WriteString(name)
+		b.WriteString("@base64/")
+		if value == "" {
+			b.WriteByte('=')
+		} else {
+			b.WriteString(base64.RawURLEncoding.EncodeToString([]byte(value)))
+		}
+		return
+	}
+	b.WriteString(name)
+	b.WriteByte('/')
+	b.WriteString(url.PathEscape(value))
+}
@@ -0,0 +1,136 @@
+package gometer
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pushedRequest struct {
+	method, path, contentType, body string
+	user, password                  string
+}
+
+func newPushgateway(t *testing.T, status int) (*httptest.Server, func() []pushedRequest) {
+	var (
+		mu       sync.Mutex
+		requests []pushedRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.Nil(t, err)
+		user, password, _ := r.BasicAuth()
+
+		mu.Lock()
+		requests = append(requests, pushedRequest{
+			method:      r.Method,
+			path:        r.URL.EscapedPath(),
+			contentType: r.Header.Get("Content-Type"),
+			body:        string(body),
+			user:        user,
+			password:    password,
+		})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	return server, func() []pushedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]pushedRequest(nil), requests...)
+	}
+}
+
+func TestPush(t *testing.T) {
+	server, requests := newPushgateway(t, http.StatusOK)
+	defer server.Close()
+
+	metrics := New()
+	require.Nil(t, metrics.GetMonotonic("processed").Add(5))
+
+	require.Nil(t, Push(metrics, PushgatewayParams{
+		URL:      server.URL + "/",
+		Job:      "backup",
+		Grouping: map[string]string{"instance": "db 1", "path": "/var/lib", "empty": ""},
+		Username: "user",
+		Password: "secret",
+	}))
+	require.Nil(t, Push(metrics, PushgatewayParams{
+		URL:    server.URL,
+		Job:    "backup",
+		Method: http.MethodPost,
+	}))
+
+	assert.Equal(t, []pushedRequest{
+		{
+			method:      http.MethodPut,
+			path:        "/metrics/job/backup/empty@base64/=/instance/db%201/path@base64/L3Zhci9saWI",
+			contentType: "text/plain; version=0.0.4",
+			body:        "# TYPE processed counter\nprocessed 5\n",
+			user:        "user",
+			password:    "secret",
+		},
+		{
+			method:      http.MethodPost,
+			path:        "/metrics/job/backup",
+			contentType: "text/plain; version=0.0.4",
+			body:        "# TYPE processed counter\nprocessed 5\n",
+		},
+	}, requests())
+}
+
+func TestPushErrors(t *testing.T) {
+	server, requests := newPushgateway(t, http.StatusBadRequest)
+	defer server.Close()
+
+	assert.NotNil(t, Push(New(), PushgatewayParams{URL: server.URL}))
+	assert.NotNil(t, Push(New(), PushgatewayParams{
+		URL:      server.URL,
+		Job:      "job",
+		Grouping: map[string]string{"job": "other"},
+	}))
+	assert.Empty(t, requests())
+	assert.NotNil(t, Push(New(), PushgatewayParams{URL: server.URL, Job: "job"}))
+	assert.Len(t, requests(), 1)
+}
+
+func TestPushgatewayPusher(t *testing.T) {
+	server, requests := newPushgateway(t, http.StatusAccepted)
+	defer server.Close()
+
+	metrics := New()
+	c := metrics.Get("step")
+
+	var errs []error
+	pusher := StartPushgatewayPusher(metrics, PushgatewayParams{
+		URL:            server.URL,
+		Job:            "cron",
+		UpdateInterval: time.Hour,
+		ErrorHandler:   func(err error) { errs = append(errs, err) },
+	})
+	c.Set(3)
+	pusher.Stop()
+
+	// the final push happens on stop.
+	pushed := requests()
+	require.Len(t, pushed, 1)
+	assert.Equal(t, "# TYPE step untyped\nstep 3\n", pushed[0].body)
+	assert.Empty(t, errs)
+}
+
+func TestPushgatewayPusherNoInterval(t *testing.T) {
+	server, requests := newPushgateway(t, http.StatusAccepted)
+	defer server.Close()
+
+	// metrics are pushed only on stop.
+	pusher := StartPushgatewayPusher(New(), PushgatewayParams{URL: server.URL, Job: "cron"})
+	assert.Empty(t, requests())
+	pusher.Stop()
+	pusher.Stop()
+	assert.Len(t, requests(), 1)
+}
@@ -0,0 +1,114 @@
+package gometer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// LoadMode determines how loaded values are combined with existing counters.
+type LoadMode int
+
+const (
+	// LoadSkip keeps values of existing counters, only missing counters are loaded.
+	LoadSkip LoadMode = iota
+	// LoadMerge adds loaded values to existing counters.
+	LoadMerge
+	// LoadOverwrite replaces values of existing counters with loaded ones.
+	LoadOverwrite
+)
+
+// LoadParams represents params for loading metrics from a file.
+//
+// FilePath represents a file path.
+// LineSeparator is a line separator used by the default formatter, "\n" is used if it's empty.
+// Mode determines how loaded values are combined with existing counters.
+type LoadParams struct {
+	FilePath      string
+	LineSeparator string
+	Mode          LoadMode
+}
+
+// Load seeds metrics with values from a file written by the default or JSON formatter,
+// e.g. by StartFileWriter(), so counters can survive process restarts.
+//
+// The root prefix is trimmed from loaded names. Names registered by GaugeFunc,
+// CounterFunc or Derive and non-integer values (gauges) are skipped.
+//
+// Missing counters are created as plain counters. Until they are claimed by Get(),
+// GetMonotonic() or callback registration adopts them with their loaded values.
+//
+// Negative values aren't loaded into monotonic counters, the rest of values are loaded
+// and an error wrapping ErrNegativeDelta is returned for the first of them.
+func (m *DefaultMetrics) Load(params LoadParams) error {
+	data, err := ioutil.ReadFile(params.FilePath)
+	if err != nil {
+		return err
+	}
+
+	lineSep := params.LineSeparator
+	if lineSep == "" {
+		lineSep = "\n"
+	}
+	snapshot, err := ParseAny(data, lineSep)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var loadErr error
+	for _, e := range snapshot.Counters {
+		name := strings.TrimPrefix(e.Name, m.rootPrefix)
+		_, isFunc := m.funcs[name]
+		_, isDerived := m.derived[name]
+		if isFunc || isDerived || e.Kind == KindGauge {
+			continue
+		}
+		value := e.Counter.Get()
+
+		if mc, ok := m.monotonic[name]; ok {
+			if err := loadMonotonic(mc, value, params.Mode); err != nil && loadErr == nil {
+				loadErr = fmt.Errorf("gometer: can't load %q: %w", e.Name, err)
+			}
+			continue
+		}
+
+		c, ok := m.counters[name]
+		if !ok {
+			c = &Counter{}
+			m.counters[name] = c
+			m.restored[name] = struct{}{}
+			c.Set(value)
+			continue
+		}
+
+		switch params.Mode {
+		case LoadMerge:
+			c.Add(value)
+		case LoadOverwrite:
+			c.Set(value)
+		}
+	}
+	return loadErr
+}
+
+func loadMonotonic(c *MonotonicCounter, value int64, mode LoadMode) error {
+	switch mode {
+	case LoadMerge:
+		return c.Add(value)
+	case LoadOverwrite:
+		if value < 0 {
+			return ErrNegativeDelta
+		}
+		c.counter.Set(value)
+	}
+	return nil
+}
+
+// Load seeds standard metrics with values from a file.
+// For more details see DefaultMetrics.Load().
+func Load(params LoadParams) error {
+	return Default.Load(params)
+}
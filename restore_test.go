@@ -0,0 +1,135 @@
+package gometer
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTempMetrics(t *testing.T, data string) string {
+	file := newTempFile(t)
+	_, err := file.WriteString(data)
+	require.Nil(t, err)
+	require.Nil(t, file.Close())
+	return file.Name()
+}
+
+func TestLoadModes(t *testing.T) {
+	t.Parallel()
+
+	path := writeTempMetrics(t, "errors = 5\nrequests = 10\nratio = 0.5\n")
+	defer os.Remove(path)
+
+	for _, tCase := range []struct {
+		mode     LoadMode
+		expected string
+	}{
+		{mode: LoadSkip, expected: `{"errors": 5, "requests": 1}`},
+		{mode: LoadMerge, expected: `{"errors": 5, "requests": 11}`},
+		{mode: LoadOverwrite, expected: `{"errors": 5, "requests": 10}`},
+	} {
+		metrics := New()
+		metrics.Get("requests").Set(1)
+
+		require.Nil(t, metrics.Load(LoadParams{FilePath: path, Mode: tCase.mode}))
+		b := metrics.GetJSON(func(string) bool { return true })
+		assert.JSONEq(t, tCase.expected, string(b), tCase.mode)
+	}
+}
+
+func TestLoadMonotonicNegative(t *testing.T) {
+	t.Parallel()
+
+	path := writeTempMetrics(t, "errors = -5\nrequests = -10\nretries = 3\n")
+	defer os.Remove(path)
+
+	for _, mode := range []LoadMode{LoadMerge, LoadOverwrite} {
+		metrics := New()
+		require.Nil(t, metrics.GetMonotonic("requests").Add(1))
+
+		err := metrics.Load(LoadParams{FilePath: path, Mode: mode})
+		assert.True(t, errors.Is(err, ErrNegativeDelta), mode)
+		assert.Equal(t, int64(1), metrics.GetMonotonic("requests").Get(), mode)
+
+		// other values are loaded, negative ones aren't adopted by monotonic counters.
+		assert.Equal(t, int64(3), metrics.GetMonotonic("retries").Get(), mode)
+		assert.Equal(t, int64(0), metrics.GetMonotonic("errors").Get(), mode)
+	}
+}
+
+func TestLoadMonotonicCreated(t *testing.T) {
+	t.Parallel()
+
+	path := writeTempMetrics(t, "requests = 10\n")
+	defer os.Remove(path)
+
+	metrics := New()
+	require.Nil(t, metrics.Load(LoadParams{FilePath: path}))
+
+	// the restored counter wasn't reset, so it has no creation time.
+	assert.True(t, metrics.GetMonotonic("requests").Created().IsZero())
+	assert.False(t, metrics.GetMonotonic("fresh").Created().IsZero())
+}
+
+func TestLoadRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	file := newTempFile(t)
+	defer removeTempFile(t, file)
+
+	metrics := New()
+	metrics.SetRootPrefix("app.")
+	metrics.SetOutput(file)
+	metrics.SetFormatter(NewFormatter(";"))
+	metrics.Get("errors").Set(-2)
+	require.Nil(t, metrics.GetMonotonic("requests").Add(42))
+	metrics.GaugeFunc("load", func() float64 { return 0.75 })
+	require.Nil(t, metrics.Write())
+
+	restored := New()
+	restored.SetRootPrefix("app.")
+	restored.CounterFunc("errors", func() int64 { return 100 })
+	require.Nil(t, restored.Load(LoadParams{FilePath: file.Name(), LineSeparator: ";"}))
+
+	// loaded counter is adopted by the monotonic one.
+	c := restored.GetMonotonic("requests")
+	assert.Equal(t, int64(42), c.Get())
+
+	b := restored.GetJSON(func(string) bool { return true })
+	assert.JSONEq(t, `{"app.errors": 100, "app.requests": 42}`, string(b))
+}
+
+func TestLoadJSON(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	metrics.Get("a").Set(1)
+	metrics.Get("b").Set(2)
+
+	path := writeTempMetrics(t, string(metrics.GetJSON(func(string) bool { return true })))
+	defer os.Remove(path)
+
+	restored := New()
+	require.Nil(t, restored.Load(LoadParams{FilePath: path}))
+	assert.Equal(t, int64(1), restored.Get("a").Get())
+	assert.Equal(t, int64(2), restored.Get("b").Get())
+
+	// claimed by Get, the counter can't be adopted anymore.
+	assert.Panics(t, func() { restored.GetMonotonic("a") })
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	assert.NotNil(t, metrics.Load(LoadParams{FilePath: "/not/existing/file"}))
+
+	for _, data := range []string{"broken line\n", "name = abc\n", `{"name": "value"}`, "{"} {
+		path := writeTempMetrics(t, data)
+		assert.NotNil(t, metrics.Load(LoadParams{FilePath: path}), data)
+		require.Nil(t, os.Remove(path))
+	}
+}
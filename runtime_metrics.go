@@ -0,0 +1,157 @@
+package gometer
+
+import (
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RuntimeMetricsParams represents params of Go runtime metrics collector.
+//
+// Samples are additional runtime/metrics names (e.g. "/gc/heap/goal:bytes").
+// Each sample is registered under its name with the leading slash removed
+// and other slashes replaced by dots, e.g. "gc.heap.goal_bytes".
+// Samples unsupported by the current Go version and histograms are skipped.
+type RuntimeMetricsParams struct {
+	Samples []string
+}
+
+// RegisterRuntimeMetrics registers Go runtime metrics in m.
+//
+// Registered metrics are: goroutines, heap.alloc_bytes, heap.inuse_bytes and
+// heap.objects gauges, gc.count and gc.pause_total_ns monotonic counters.
+// They are refreshed on each write using runtime/metrics and runtime/debug,
+// so the world isn't stopped on every flush.
+//
+// Use PrefixMetrics to group them, e.g.:
+//
+//	gometer.RegisterRuntimeMetrics(metrics.WithPrefix("runtime."), gometer.RuntimeMetricsParams{})
+func RegisterRuntimeMetrics(m Metrics, params RuntimeMetricsParams) {
+	c := newRuntimeCollector(params.Samples)
+
+	m.GaugeFunc("goroutines", func() float64 {
+		return c.sample().value(runtimeGoroutines)
+	})
+	m.GaugeFunc("heap.alloc_bytes", func() float64 {
+		return c.sample().value(runtimeHeapObjectsBytes)
+	})
+	m.GaugeFunc("heap.inuse_bytes", func() float64 {
+		s := c.sample()
+		return s.value(runtimeHeapObjectsBytes) + s.value(runtimeHeapUnusedBytes)
+	})
+	m.GaugeFunc("heap.objects", func() float64 {
+		return c.sample().value(runtimeHeapObjects)
+	})
+	m.MonotonicFunc("gc.count", func() int64 {
+		return c.sample().gc.NumGC
+	})
+	m.MonotonicFunc("gc.pause_total_ns", func() int64 {
+		return int64(c.sample().gc.PauseTotal)
+	})
+
+	for _, name := range c.extra {
+		name := name
+		m.GaugeFunc(runtimeMetricName(name), func() float64 {
+			return c.sample().value(name)
+		})
+	}
+}
+
+const (
+	runtimeGoroutines       = "/sched/goroutines:goroutines"
+	runtimeHeapObjectsBytes = "/memory/classes/heap/objects:bytes"
+	runtimeHeapUnusedBytes  = "/memory/classes/heap/unused:bytes"
+	runtimeHeapObjects      = "/gc/heap/objects:objects"
+)
+
+// runtimeMetricName converts runtime/metrics name to metric name.
+func runtimeMetricName(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	name = strings.Replace(name, "/", ".", -1)
+	return strings.Replace(name, ":", "_", -1)
+}
+
+type runtimeCollector struct {
+	extra []string
+
+	mu      sync.Mutex
+	samples []metrics.Sample
+	last    *runtimeSample
+}
+
+type runtimeSample struct {
+	time   time.Time
+	values map[string]float64
+	gc     debug.GCStats
+}
+
+func (s *runtimeSample) value(name string) float64 {
+	return s.values[name]
+}
+
+func newRuntimeCollector(extra []string) *runtimeCollector {
+	supported := make(map[string]metrics.ValueKind)
+	for _, d := range metrics.All() {
+		supported[d.Name] = d.Kind
+	}
+
+	c := &runtimeCollector{}
+	add := func(name string) bool {
+		kind, ok := supported[name]
+		if !ok || (kind != metrics.KindUint64 && kind != metrics.KindFloat64) {
+			return false
+		}
+		c.samples = append(c.samples, metrics.Sample{Name: name})
+		return true
+	}
+
+	for _, name := range []string{
+		runtimeGoroutines,
+		runtimeHeapObjectsBytes,
+		runtimeHeapUnusedBytes,
+		runtimeHeapObjects,
+	} {
+		add(name)
+	}
+	for _, name := range extra {
+		if add(name) {
+			c.extra = append(c.extra, name)
+		}
+	}
+	return c
+}
+
+// sample returns runtime values reading them if the last sample is outdated.
+func (c *runtimeCollector) sample() *runtimeSample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.last != nil && time.Since(c.last.time) < collectorSampleTTL {
+		return c.last
+	}
+
+	metrics.Read(c.samples)
+
+	s := &runtimeSample{
+		time:   time.Now(),
+		values: make(map[string]float64, len(c.samples)),
+	}
+	for _, sample := range c.samples {
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			s.values[sample.Name] = float64(sample.Value.Uint64())
+		case metrics.KindFloat64:
+			s.values[sample.Name] = sample.Value.Float64()
+		}
+	}
+	if _, ok := s.values[runtimeGoroutines]; !ok {
+		s.values[runtimeGoroutines] = float64(runtime.NumGoroutine())
+	}
+	debug.ReadGCStats(&s.gc)
+
+	c.last = s
+	return s
+}
@@ -0,0 +1,55 @@
+package gometer
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeMetrics(t *testing.T) {
+	t.Parallel()
+
+	metrics := New()
+	RegisterRuntimeMetrics(metrics.WithPrefix("runtime."), RuntimeMetricsParams{
+		Samples: []string{"/gc/heap/goal:bytes", "/unknown:bytes"},
+	})
+	runtime.GC()
+
+	s := metrics.Snapshot()
+	for _, name := range []string{
+		"runtime.goroutines",
+		"runtime.heap.alloc_bytes",
+		"runtime.heap.inuse_bytes",
+		"runtime.heap.objects",
+		"runtime.gc.count",
+		"runtime.gc.heap.goal_bytes",
+	} {
+		e, ok := s.Get(name)
+		require.True(t, ok, name)
+		assert.True(t, e.Value() > 0, name)
+	}
+
+	for name, kind := range map[string]Kind{
+		"runtime.goroutines":        KindGauge,
+		"runtime.heap.alloc_bytes":  KindGauge,
+		"runtime.heap.inuse_bytes":  KindGauge,
+		"runtime.heap.objects":      KindGauge,
+		"runtime.gc.count":          KindMonotonic,
+		"runtime.gc.pause_total_ns": KindMonotonic,
+	} {
+		e, _ := s.Get(name)
+		assert.Equal(t, kind, e.Kind, name)
+	}
+
+	_, ok := s.Get("runtime.unknown_bytes")
+	assert.False(t, ok)
+	_, ok = s.Get("runtime.gc.pause_total_ns")
+	assert.True(t, ok)
+}
+
+func TestRuntimeMetricName(t *testing.T) {
+	assert.Equal(t, "gc.heap.goal_bytes", runtimeMetricName("/gc/heap/goal:bytes"))
+	assert.Equal(t, "sched.goroutines_goroutines", runtimeMetricName("/sched/goroutines:goroutines"))
+}
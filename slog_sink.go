@@ -0,0 +1,109 @@
+//go:build go1.21
+// +build go1.21
+
+package gometer
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// SlogSinkParams represents params of a sink that logs metrics through log/slog.
+//
+// Logger is used to log records, slog.Default() is used if it's nil.
+// Message is a message of records, "metrics" is used if it's empty.
+// Separator splits names into nested groups of attributes, "." is used if it's empty.
+// UpdateInterval must be positive.
+// Delta makes the sink log increments of counters since its previous record
+// instead of absolute values, gauges are logged as is.
+// NoFlushOnStop disables logging of metrics when the sink is stopped.
+type SlogSinkParams struct {
+	Logger         *slog.Logger
+	Level          slog.Level
+	Message        string
+	Separator      string
+	UpdateInterval time.Duration
+	Delta          bool
+	NoFlushOnStop  bool
+}
+
+// StartSlogSink starts a goroutine that logs a record with metrics of m every params.UpdateInterval.
+//
+// Metrics are attributes of a record grouped by prefixes of their names,
+// e.g. "db.reads" and "db.errors" are logged as group "db" with attributes
+// "reads" and "errors". A value of a name that is also a prefix of other names
+// is logged with "_value" key inside its group.
+func StartSlogSink(m Metrics, params SlogSinkParams) Stopper {
+	if params.Logger == nil {
+		params.Logger = slog.Default()
+	}
+	if params.Message == "" {
+		params.Message = "metrics"
+	}
+	if params.Separator == "" {
+		params.Separator = "."
+	}
+
+	return startPeriodic(params.UpdateInterval, !params.NoFlushOnStop, newSlogFlush(m, params))
+}
+
+// newSlogFlush returns a function that logs a record with metrics of m,
+// with params.Delta consecutive calls log increments since the previous one.
+func newSlogFlush(m Metrics, params SlogSinkParams) func() {
+	var deltas *deltaTracker
+	if params.Delta {
+		deltas = &deltaTracker{}
+	}
+
+	return func() {
+		s := m.Snapshot()
+		counters := s.Counters
+		if deltas != nil {
+			counters = deltas.next(counters)
+		}
+		logSnapshot(params, s.Time, counters)
+	}
+}
+
+func logSnapshot(params SlogSinkParams, t time.Time, counters SortedCounters) {
+	ctx := context.Background()
+	if !params.Logger.Enabled(ctx, params.Level) {
+		return
+	}
+
+	r := slog.NewRecord(t, params.Level, params.Message, 0)
+	r.AddAttrs(slogAttrs(newNestedTree(counters, params.Separator))...)
+	_ = params.Logger.Handler().Handle(ctx, r)
+}
+
+// slogAttrs converts children of a node to attributes sorted by key.
+func slogAttrs(n *nestedNode) []slog.Attr {
+	keys := make([]string, 0, len(n.children))
+	for key := range n.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys)+1)
+	if n.entry != nil {
+		attrs = append(attrs, slogAttr(nestedValueKey, *n.entry))
+	}
+	for _, key := range keys {
+		child := n.children[key]
+		if len(child.children) == 0 {
+			attrs = append(attrs, slogAttr(key, *child.entry))
+			continue
+		}
+		attrs = append(attrs, slog.Attr{Key: key, Value: slog.GroupValue(slogAttrs(child)...)})
+	}
+	return attrs
+}
+
+func slogAttr(key string, e CounterEntry) slog.Attr {
+	if e.Kind == KindGauge {
+		return slog.Float64(key, e.Gauge)
+	}
+	return slog.Int64(key, e.Counter.Get())
+}
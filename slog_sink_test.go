@@ -0,0 +1,67 @@
+//go:build go1.21
+// +build go1.21
+
+package gometer
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogSink(t *testing.T) {
+	metrics := New()
+	metrics.Get("db.reads").Set(10)
+	metrics.Get("db").Set(1)
+	metrics.GetMonotonic("db.errors.timeout").Inc()
+	metrics.GaugeFunc("load", func() float64 { return 0.5 })
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	params := SlogSinkParams{
+		Logger:         logger,
+		UpdateInterval: time.Hour,
+		Delta:          true,
+	}
+	sink := StartSlogSink(metrics, params)
+	sink.Stop()
+	assert.JSONEq(t, `{
+		"level": "INFO",
+		"msg": "metrics",
+		"db": {"_value": 1, "errors": {"timeout": 1}, "reads": 10},
+		"load": 0.5
+	}`, buf.String())
+
+	// next records contain increments since the previous one.
+	buf.Reset()
+	params.Message, params.Separator = "metrics", "."
+	flush := newSlogFlush(metrics, params)
+	flush()
+	buf.Reset()
+	metrics.Get("db.reads").Set(15)
+	metrics.GetMonotonic("db.errors.timeout").Inc()
+	flush()
+	assert.JSONEq(t, `{
+		"level": "INFO",
+		"msg": "metrics",
+		"db": {"_value": 0, "errors": {"timeout": 1}, "reads": 5},
+		"load": 0.5
+	}`, buf.String())
+
+	// disabled levels aren't logged.
+	buf.Reset()
+	params.Level = slog.LevelDebug
+	StartSlogSink(metrics, params).Stop()
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,22 @@
+package gometer
+
+import "time"
+
+// Snapshot represents values of metrics at a moment of time.
+//
+// Counters of a snapshot are detached from the metrics they were taken from,
+// so they aren't changed by further updates.
+type Snapshot struct {
+	Time     time.Time
+	Counters SortedCounters
+}
+
+// Get returns an entry by name.
+func (s Snapshot) Get(name string) (CounterEntry, bool) {
+	for _, e := range s.Counters {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return CounterEntry{}, false
+}
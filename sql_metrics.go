@@ -0,0 +1,410 @@
+package gometer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
+)
+
+// RegisterDBStats registers connection pool stats of db in m with specified prefix.
+//
+// Registered metrics are: max_open, open, in_use and idle gauges, wait_count,
+// wait_duration_ns, max_idle_closed, max_idle_time_closed and max_lifetime_closed
+// monotonic counters. They are refreshed on each write, see sql.DBStats for their meaning.
+func RegisterDBStats(m Metrics, prefix string, db *sql.DB) {
+	c := &dbStatsCollector{db: db}
+	pm := m.WithPrefix(prefix)
+
+	for _, f := range []struct {
+		name  string
+		value func(s sql.DBStats) int
+	}{
+		{name: "max_open", value: func(s sql.DBStats) int { return s.MaxOpenConnections }},
+		{name: "open", value: func(s sql.DBStats) int { return s.OpenConnections }},
+		{name: "in_use", value: func(s sql.DBStats) int { return s.InUse }},
+		{name: "idle", value: func(s sql.DBStats) int { return s.Idle }},
+	} {
+		value := f.value
+		pm.GaugeFunc(f.name, func() float64 {
+			return float64(value(c.stats()))
+		})
+	}
+
+	for _, f := range []struct {
+		name  string
+		value func(s sql.DBStats) int64
+	}{
+		{name: "wait_count", value: func(s sql.DBStats) int64 { return s.WaitCount }},
+		{name: "wait_duration_ns", value: func(s sql.DBStats) int64 { return int64(s.WaitDuration) }},
+		{name: "max_idle_closed", value: func(s sql.DBStats) int64 { return s.MaxIdleClosed }},
+		{name: "max_idle_time_closed", value: func(s sql.DBStats) int64 { return s.MaxIdleTimeClosed }},
+		{name: "max_lifetime_closed", value: func(s sql.DBStats) int64 { return s.MaxLifetimeClosed }},
+	} {
+		value := f.value
+		pm.MonotonicFunc(f.name, func() int64 {
+			return value(c.stats())
+		})
+	}
+}
+
+type dbStatsCollector struct {
+	db *sql.DB
+
+	mu   sync.Mutex
+	time time.Time
+	last sql.DBStats
+}
+
+// stats returns db stats reading them if the last ones are outdated.
+func (c *dbStatsCollector) stats() sql.DBStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.time) >= collectorSampleTTL {
+		c.last = c.db.Stats()
+		c.time = time.Now()
+	}
+	return c.last
+}
+
+// sqlOp represents counters of one type of database operation.
+type sqlOp struct {
+	count      *MonotonicCounter
+	errors     *MonotonicCounter
+	durationNS *MonotonicCounter
+}
+
+func (op *sqlOp) observe(start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+	op.count.Inc()
+	_ = op.durationNS.Add(int64(time.Since(start)))
+	if err != nil {
+		op.errors.Inc()
+	}
+}
+
+type sqlMetrics struct {
+	query    sqlOp
+	exec     sqlOp
+	prepare  sqlOp
+	begin    sqlOp
+	commit   sqlOp
+	rollback sqlOp
+}
+
+func newSQLMetrics(m Metrics) *sqlMetrics {
+	sm := &sqlMetrics{}
+	for _, op := range []struct {
+		name string
+		op   *sqlOp
+	}{
+		{name: "query", op: &sm.query},
+		{name: "exec", op: &sm.exec},
+		{name: "prepare", op: &sm.prepare},
+		{name: "begin", op: &sm.begin},
+		{name: "commit", op: &sm.commit},
+		{name: "rollback", op: &sm.rollback},
+	} {
+		pm := m.WithPrefix("%s.", op.name)
+		*op.op = sqlOp{
+			count:      pm.GetMonotonic("count"),
+			errors:     pm.GetMonotonic("errors"),
+			durationNS: pm.GetMonotonic("duration_ns"),
+		}
+	}
+	return sm
+}
+
+// WrapDriver returns a driver that counts operations of d in m.
+//
+// For each of query, exec, prepare, begin, commit and rollback operations
+// <op>.count, <op>.errors and <op>.duration_ns are recorded.
+// Use PrefixMetrics to separate metrics of several databases, e.g.:
+//
+//	sql.Register("postgres-metered", gometer.WrapDriver(&pq.Driver{}, metrics.WithPrefix("db.")))
+func WrapDriver(d driver.Driver, m Metrics) driver.Driver {
+	return &sqlDriver{Driver: d, metrics: newSQLMetrics(m)}
+}
+
+// WrapConnector returns a connector that counts operations of connections made by c in m.
+// It's intended for sql.OpenDB, for more details see WrapDriver.
+func WrapConnector(c driver.Connector, m Metrics) driver.Connector {
+	return &sqlConnector{
+		connector: c,
+		driver:    &sqlDriver{Driver: c.Driver(), metrics: newSQLMetrics(m)},
+	}
+}
+
+type sqlDriver struct {
+	driver.Driver
+	metrics *sqlMetrics
+}
+
+func (d *sqlDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{Conn: conn, metrics: d.metrics}, nil
+}
+
+type sqlConnector struct {
+	connector driver.Connector
+	driver    *sqlDriver
+}
+
+func (c *sqlConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{Conn: conn, metrics: c.driver.metrics}, nil
+}
+
+func (c *sqlConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// sqlConn implements optional interfaces of driver.Conn,
+// falling back to the default behavior if the wrapped connection doesn't.
+type sqlConn struct {
+	driver.Conn
+	metrics *sqlMetrics
+}
+
+var (
+	_ driver.ConnPrepareContext = (*sqlConn)(nil)
+	_ driver.ConnBeginTx        = (*sqlConn)(nil)
+	_ driver.ExecerContext      = (*sqlConn)(nil)
+	_ driver.QueryerContext     = (*sqlConn)(nil)
+	_ driver.Pinger             = (*sqlConn)(nil)
+	_ driver.SessionResetter    = (*sqlConn)(nil)
+	_ driver.NamedValueChecker  = (*sqlConn)(nil)
+	_ driver.Validator          = (*sqlConn)(nil)
+)
+
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqlConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	start := time.Now()
+	defer func() { c.metrics.prepare.observe(start, err) }()
+
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newSQLStmt(stmt, c), nil
+}
+
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
+	start := time.Now()
+	defer func() { c.metrics.begin.observe(start, err) }()
+
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		// options can't be passed to Begin, so they're rejected as by database/sql.
+		switch {
+		case opts.Isolation != driver.IsolationLevel(sql.LevelDefault):
+			return nil, errors.New("gometer: driver does not support non-default isolation level")
+		case opts.ReadOnly:
+			return nil, errors.New("gometer: driver does not support read-only transactions")
+		}
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, metrics: c.metrics}, nil
+}
+
+func (c *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (res driver.Result, err error) {
+	start := time.Now()
+	defer func() { c.metrics.exec.observe(start, err) }()
+
+	switch e := c.Conn.(type) {
+	case driver.ExecerContext:
+		return e.ExecContext(ctx, query, args)
+	case driver.Execer:
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return e.Exec(query, values)
+	default:
+		return nil, driver.ErrSkip
+	}
+}
+
+func (c *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	start := time.Now()
+	defer func() { c.metrics.query.observe(start, err) }()
+
+	switch q := c.Conn.(type) {
+	case driver.QueryerContext:
+		return q.QueryContext(ctx, query, args)
+	case driver.Queryer:
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return q.Query(query, values)
+	default:
+		return nil, driver.ErrSkip
+	}
+}
+
+func (c *sqlConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) CheckNamedValue(v *driver.NamedValue) error {
+	if ch, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func (c *sqlConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+type sqlTx struct {
+	driver.Tx
+	metrics *sqlMetrics
+}
+
+func (tx *sqlTx) Commit() (err error) {
+	start := time.Now()
+	defer func() { tx.metrics.commit.observe(start, err) }()
+	return tx.Tx.Commit()
+}
+
+func (tx *sqlTx) Rollback() (err error) {
+	start := time.Now()
+	defer func() { tx.metrics.rollback.observe(start, err) }()
+	return tx.Tx.Rollback()
+}
+
+// sqlStmt implements optional interfaces of driver.Stmt like sqlConn does.
+// driver.ColumnConverter changes how database/sql converts arguments,
+// so it's implemented by sqlConverterStmt only if the wrapped statement does.
+type sqlStmt struct {
+	driver.Stmt
+	conn    *sqlConn
+	metrics *sqlMetrics
+}
+
+var (
+	_ driver.StmtExecContext   = (*sqlStmt)(nil)
+	_ driver.StmtQueryContext  = (*sqlStmt)(nil)
+	_ driver.NamedValueChecker = (*sqlStmt)(nil)
+	_ driver.ColumnConverter   = (*sqlConverterStmt)(nil)
+)
+
+type sqlConverterStmt struct {
+	*sqlStmt
+	converter driver.ColumnConverter
+}
+
+func newSQLStmt(stmt driver.Stmt, c *sqlConn) driver.Stmt {
+	s := &sqlStmt{Stmt: stmt, conn: c, metrics: c.metrics}
+	if cc, ok := stmt.(driver.ColumnConverter); ok {
+		return &sqlConverterStmt{sqlStmt: s, converter: cc}
+	}
+	return s
+}
+
+func (s *sqlConverterStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return s.converter.ColumnConverter(idx)
+}
+
+// CheckNamedValue uses a checker of the wrapped statement or, as database/sql
+// does without it, the one of the connection.
+func (s *sqlStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if ch, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(v)
+	}
+	return s.conn.CheckNamedValue(v)
+}
+
+func (s *sqlStmt) Exec(args []driver.Value) (res driver.Result, err error) {
+	start := time.Now()
+	defer func() { s.metrics.exec.observe(start, err) }()
+	return s.Stmt.Exec(args)
+}
+
+func (s *sqlStmt) Query(args []driver.Value) (rows driver.Rows, err error) {
+	start := time.Now()
+	defer func() { s.metrics.query.observe(start, err) }()
+	return s.Stmt.Query(args)
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
+	e, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Exec(values)
+	}
+
+	start := time.Now()
+	defer func() { s.metrics.exec.observe(start, err) }()
+	return e.ExecContext(ctx, args)
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	q, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Query(values)
+	}
+
+	start := time.Now()
+	defer func() { s.metrics.query.observe(start, err) }()
+	return q.QueryContext(ctx, args)
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("gometer: driver doesn't support named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,249 @@
+package gometer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver implements the minimal set of driver interfaces.
+// Queries equal to "fail" return an error.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query: query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	if s.query == "fail" {
+		return nil, errors.New("exec failed")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	if s.query == "fail" {
+		return nil, errors.New("query failed")
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeRows struct {
+	done bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(42)
+	return nil
+}
+
+type fakeConnector struct {
+	conn driver.Conn
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	if c.conn == nil {
+		return fakeConn{}, nil
+	}
+	return c.conn, nil
+}
+
+func (fakeConnector) Driver() driver.Driver { return fakeDriver{} }
+
+// convertingConn prepares statements that convert arguments to strings
+// and records arguments of executed statements.
+type convertingConn struct {
+	fakeConn
+	args *[]driver.Value
+}
+
+func (c convertingConn) Prepare(query string) (driver.Stmt, error) {
+	return convertingStmt{fakeStmt: fakeStmt{query: query}, args: c.args}, nil
+}
+
+type convertingStmt struct {
+	fakeStmt
+	args *[]driver.Value
+}
+
+func (s convertingStmt) ColumnConverter(int) driver.ValueConverter {
+	return stringConverter{}
+}
+
+func (s convertingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	*s.args = args
+	return s.fakeStmt.Exec(args)
+}
+
+type stringConverter struct{}
+
+func (stringConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	return fmt.Sprint(v), nil
+}
+
+// checkingConn rejects arguments that aren't strings.
+type checkingConn struct {
+	fakeConn
+}
+
+func (checkingConn) CheckNamedValue(v *driver.NamedValue) error {
+	if _, ok := v.Value.(string); !ok {
+		return errors.New("only strings are supported")
+	}
+	return nil
+}
+
+var sqlDriverMetrics = New()
+
+func init() {
+	sql.Register("gometer-fake", WrapDriver(fakeDriver{}, sqlDriverMetrics.WithPrefix("test.sql.")))
+}
+
+func TestSQLRegisteredDriver(t *testing.T) {
+	db, err := sql.Open("gometer-fake", "")
+	require.Nil(t, err)
+	defer db.Close()
+
+	prev := sqlDriverMetrics.Snapshot()
+
+	var v int64
+	require.Nil(t, db.QueryRow("select").Scan(&v))
+	assert.Equal(t, int64(42), v)
+	_, err = db.Exec("fail")
+	require.NotNil(t, err)
+
+	// the registry is shared by runs of the test, so increments are checked.
+	deltas := make(map[string]int64)
+	for _, e := range Diff(prev, sqlDriverMetrics.Snapshot(), DiffParams{}).Deltas() {
+		deltas[e.Name] = e.Counter.Get()
+	}
+	assert.Equal(t, int64(1), deltas["test.sql.query.count"])
+	assert.Equal(t, int64(0), deltas["test.sql.query.errors"])
+	assert.Equal(t, int64(1), deltas["test.sql.exec.count"])
+	assert.Equal(t, int64(1), deltas["test.sql.exec.errors"])
+	assert.Equal(t, int64(2), deltas["test.sql.prepare.count"])
+}
+
+func TestSQLWrapDriver(t *testing.T) {
+	metrics := New()
+	db := sql.OpenDB(WrapConnector(fakeConnector{}, metrics.WithPrefix("test.sql.")))
+	defer db.Close()
+
+	var v int64
+	require.Nil(t, db.QueryRow("select", 1).Scan(&v))
+	assert.Equal(t, int64(42), v)
+
+	_, err := db.Exec("insert", 1)
+	require.Nil(t, err)
+	_, err = db.Exec("fail")
+	require.NotNil(t, err)
+
+	tx, err := db.Begin()
+	require.Nil(t, err)
+	require.Nil(t, tx.Commit())
+
+	// fakeConn doesn't support options of transactions.
+	_, err = db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	assert.NotNil(t, err)
+	_, err = db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	assert.NotNil(t, err)
+
+	pm := metrics.WithPrefix("test.sql.")
+	assert.Equal(t, int64(1), pm.GetMonotonic("query.count").Get())
+	assert.Equal(t, int64(0), pm.GetMonotonic("query.errors").Get())
+	assert.Equal(t, int64(2), pm.GetMonotonic("exec.count").Get())
+	assert.Equal(t, int64(1), pm.GetMonotonic("exec.errors").Get())
+	assert.Equal(t, int64(3), pm.GetMonotonic("prepare.count").Get())
+	assert.Equal(t, int64(3), pm.GetMonotonic("begin.count").Get())
+	assert.Equal(t, int64(2), pm.GetMonotonic("begin.errors").Get())
+	assert.Equal(t, int64(1), pm.GetMonotonic("commit.count").Get())
+	assert.Equal(t, int64(0), pm.GetMonotonic("rollback.count").Get())
+	assert.True(t, pm.GetMonotonic("exec.duration_ns").Get() > 0)
+}
+
+func TestSQLWrapDriverConversion(t *testing.T) {
+	var args []driver.Value
+	db := sql.OpenDB(WrapConnector(fakeConnector{conn: convertingConn{args: &args}}, New()))
+	defer db.Close()
+
+	// the column converter of the wrapped statement is used.
+	_, err := db.Exec("insert", 1, true)
+	require.Nil(t, err)
+	assert.Equal(t, []driver.Value{"1", "true"}, args)
+
+	db = sql.OpenDB(WrapConnector(fakeConnector{conn: checkingConn{}}, New()))
+	defer db.Close()
+
+	// the named value checker of the wrapped connection is used.
+	_, err = db.Exec("insert", "text")
+	require.Nil(t, err)
+	_, err = db.Exec("insert", 1)
+	assert.NotNil(t, err)
+
+	stmt, err := (&sqlConn{Conn: fakeConn{}, metrics: newSQLMetrics(New())}).Prepare("select")
+	require.Nil(t, err)
+	_, ok := stmt.(driver.ColumnConverter)
+	assert.False(t, ok)
+}
+
+func TestSQLDBStats(t *testing.T) {
+	db, err := sql.Open("gometer-fake", "")
+	require.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(5)
+
+	conn, err := db.Conn(context.Background())
+	require.Nil(t, err)
+
+	metrics := New()
+	RegisterDBStats(metrics, "db.", db)
+
+	s := metrics.Snapshot()
+	for name, expected := range map[string]float64{
+		"db.max_open": 5,
+		"db.open":     1,
+		"db.in_use":   1,
+		"db.idle":     0,
+	} {
+		e, ok := s.Get(name)
+		require.True(t, ok, name)
+		assert.Equal(t, expected, e.Value(), name)
+		assert.Equal(t, KindGauge, e.Kind, name)
+	}
+	for _, name := range []string{"db.wait_count", "db.wait_duration_ns", "db.max_idle_closed"} {
+		e, ok := s.Get(name)
+		require.True(t, ok, name)
+		assert.Equal(t, KindMonotonic, e.Kind, name)
+	}
+	require.Nil(t, conn.Close())
+}
@@ -1,5 +1,10 @@
 package gometer
 
+import (
+	"sync"
+	"time"
+)
+
 // Stopper is used to stop started entities.
 type Stopper interface {
 	Stop()
@@ -16,3 +21,45 @@ func (s *stopperFunc) Stop() {
 		s.stop()
 	}
 }
+
+// startPeriodic starts a goroutine that calls fn every interval until it's stopped.
+// If flushOnStop is set, fn is called once more when the goroutine finishes.
+// It panics if interval isn't positive.
+func startPeriodic(interval time.Duration, flushOnStop bool, fn func()) Stopper {
+	if interval <= 0 {
+		panic("gometer: non-positive interval of a periodic goroutine")
+	}
+
+	var (
+		stopOnce sync.Once
+		cancelCh = make(chan struct{})
+		doneCh   = make(chan struct{})
+	)
+
+	go func() {
+		defer close(doneCh)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-cancelCh:
+				return
+			}
+		}
+	}()
+
+	return &stopperFunc{stop: func() {
+		stopOnce.Do(func() {
+			close(cancelCh)
+
+			<-doneCh
+			if flushOnStop {
+				fn()
+			}
+		})
+	}}
+}
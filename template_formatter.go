@@ -0,0 +1,137 @@
+package gometer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData represents data passed to a template of a template formatter.
+type TemplateData struct {
+	Time     time.Time
+	Counters SortedCounters
+}
+
+var templateFuncs = template.FuncMap{
+	"sanitize":   SanitizePrometheusName,
+	"value":      formatValue,
+	"trimPrefix": func(prefix, name string) string { return strings.TrimPrefix(name, prefix) },
+	"segment":    templateSegment,
+	"labels":     templateLabels,
+	"tags":       templateTags,
+	"unix":       func(t time.Time) int64 { return t.Unix() },
+	"unixMilli":  func(t time.Time) int64 { return t.UnixNano() / int64(time.Millisecond) },
+	"rfc3339":    func(t time.Time) string { return t.Format(time.RFC3339) },
+	"quote": func(s string) string {
+		var buf bytes.Buffer
+		writeJSONString(&buf, s)
+		return buf.String()
+	},
+}
+
+func templateSegment(sep string, i int, name string) string {
+	segments := strings.Split(name, sep)
+	if i < 0 {
+		i += len(segments)
+	}
+	if i < 0 || i >= len(segments) {
+		return ""
+	}
+	return segments[i]
+}
+
+func templateLabels(kv ...string) (string, error) {
+	if len(kv)%2 != 0 {
+		return "", errors.New("odd number of labels arguments")
+	}
+	if len(kv) == 0 {
+		return "", nil
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(SanitizePrometheusName(kv[i]))
+		b.WriteString(`="`)
+		b.WriteString(prometheusLabelEscaper.Replace(kv[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+func templateTags(kv ...string) (string, error) {
+	if len(kv)%2 != 0 {
+		return "", errors.New("odd number of tags arguments")
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(kv); i += 2 {
+		b.WriteByte(',')
+		b.WriteString(influxFieldKeyEscaper.Replace(kv[i]))
+		b.WriteByte('=')
+		b.WriteString(influxFieldKeyEscaper.Replace(kv[i+1]))
+	}
+	return b.String(), nil
+}
+
+// NewTemplateFormatter returns a formatter that executes a text/template with TemplateData,
+// e.g. "{{range .Counters}}{{sanitize .Name}} {{value .}} {{unix $.Time}}\n{{end}}".
+//
+// Besides the standard functions of text/template the following ones are available:
+//
+//	sanitize NAME           - NAME converted by SanitizePrometheusName
+//	value ENTRY             - a value of ENTRY as written by NewFormatter
+//	trimPrefix PREFIX NAME  - NAME without leading PREFIX
+//	segment SEP INDEX NAME  - INDEX-th segment of NAME split by SEP or an empty string,
+//	                          negative INDEX counts from the end
+//	labels KEY VALUE ...    - Prometheus labels, e.g. {k1="v1",k2="v2"}
+//	tags KEY VALUE ...      - InfluxDB line protocol tags, e.g. ,k1=v1,k2=v2
+//	unix TIME               - TIME in Unix seconds
+//	unixMilli TIME          - TIME in Unix milliseconds
+//	rfc3339 TIME            - TIME in RFC 3339 format
+//	quote STRING            - STRING as a JSON string
+//
+// The template is validated by executing it with sample metrics, so an error is
+// returned for both syntax errors and invalid field references. If execution fails
+// while formatting, the output written before the failure is returned.
+func NewTemplateFormatter(text string) (Formatter, error) {
+	tmpl, err := template.New("gometer").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("gometer: invalid template: %v", err)
+	}
+
+	c := &Counter{}
+	c.Set(1)
+	sample := TemplateData{
+		Time: time.Unix(0, 0),
+		Counters: SortedCounters{
+			{Name: "sample.counter", Counter: c, Kind: KindCounter},
+			newGaugeEntry("sample.gauge", 0.5),
+		},
+	}
+	if err := tmpl.Execute(ioutil.Discard, sample); err != nil {
+		return nil, fmt.Errorf("gometer: invalid template: %v", err)
+	}
+
+	return &templateFormatter{tmpl: tmpl}, nil
+}
+
+type templateFormatter struct {
+	tmpl *template.Template
+}
+
+func (f *templateFormatter) Format(counters SortedCounters) []byte {
+	var buf bytes.Buffer
+	_ = f.tmpl.Execute(&buf, TemplateData{Time: time.Now(), Counters: counters})
+	return buf.Bytes()
+}
+
+var _ Formatter = (*templateFormatter)(nil)